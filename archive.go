@@ -0,0 +1,232 @@
+package grdep
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"math"
+	"os"
+	"path"
+	"strings"
+)
+
+// ArchiveSeparator separates the path of an archive and the path of a file in it,
+// e.g. bundle.tar.gz!/etc/app/start.sh.
+const ArchiveSeparator = "!/"
+
+var (
+	ErrArchive = errors.New("Archive")
+	// ErrArchiveEntryTooLarge is the error of a file in an archive larger than the max entry size.
+	ErrArchiveEntryTooLarge = errors.New("ArchiveEntryTooLarge")
+)
+
+// DefaultArchiveMaxEntrySize is the default max size of a file in archives to read into memory.
+const DefaultArchiveMaxEntrySize = 64 << 20
+
+type archiveKind int
+
+const (
+	archiveNone archiveKind = iota
+	archiveTar
+	archiveTarGzip
+	archiveTarBzip2
+	archiveZip
+)
+
+var archiveSuffixes = []struct {
+	suffix string
+	kind   archiveKind
+}{
+	{suffix: ".tar", kind: archiveTar},
+	{suffix: ".tar.gz", kind: archiveTarGzip},
+	{suffix: ".tgz", kind: archiveTarGzip},
+	{suffix: ".tar.bz2", kind: archiveTarBzip2},
+	{suffix: ".tbz2", kind: archiveTarBzip2},
+	{suffix: ".zip", kind: archiveZip},
+	{suffix: ".jar", kind: archiveZip},
+	{suffix: ".war", kind: archiveZip},
+}
+
+func archiveKindOf(name string) archiveKind {
+	name = strings.ToLower(name)
+	for _, x := range archiveSuffixes {
+		if strings.HasSuffix(name, x.suffix) {
+			return x.kind
+		}
+	}
+	return archiveNone
+}
+
+// IsArchive returns true if the name is an archive that the walker can descend into.
+func IsArchive(name string) bool {
+	return archiveKindOf(name) != archiveNone
+}
+
+// archiveEntry is a regular file in an archive.
+type archiveEntry struct {
+	name    string
+	info    fs.FileInfo
+	content []byte
+	// err is not nil if the content is not read, e.g. ErrArchiveEntryTooLarge.
+	err error
+}
+
+// readArchive reads the regular files in the archive, the content of each file is read up to maxSize bytes.
+// An error is the last entry, a file larger than maxSize is an entry with err.
+func readArchive(kind archiveKind, r io.Reader, maxSize int64) iter.Seq2[archiveEntry, error] {
+	switch kind {
+	case archiveTar:
+		return readTar(r, maxSize)
+	case archiveTarGzip:
+		return func(yield func(archiveEntry, error) bool) {
+			gr, err := gzip.NewReader(r)
+			if err != nil {
+				yield(archiveEntry{}, err)
+				return
+			}
+			defer gr.Close()
+			readTar(gr, maxSize)(yield)
+		}
+	case archiveTarBzip2:
+		return readTar(bzip2.NewReader(r), maxSize)
+	case archiveZip:
+		return readZip(r, maxSize)
+	default:
+		return func(yield func(archiveEntry, error) bool) {
+			yield(archiveEntry{}, fmt.Errorf("%w: unknown archive", ErrArchive))
+		}
+	}
+}
+
+// readArchiveEntry reads the content up to maxSize bytes, size is the declared size.
+// Non-positive maxSize means no limit.
+func readArchiveEntry(r io.Reader, size, maxSize int64) ([]byte, error) {
+	if maxSize <= 0 {
+		return io.ReadAll(r)
+	}
+	if size > maxSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrArchiveEntryTooLarge, size)
+	}
+	b, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > maxSize {
+		return nil, fmt.Errorf("%w: over %d bytes", ErrArchiveEntryTooLarge, maxSize)
+	}
+	return b, nil
+}
+
+func readTar(r io.Reader, maxSize int64) iter.Seq2[archiveEntry, error] {
+	return func(yield func(archiveEntry, error) bool) {
+		tr := tar.NewReader(r)
+		for {
+			header, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(archiveEntry{}, err)
+				return
+			}
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			entry := archiveEntry{
+				name: cleanArchiveName(header.Name),
+				info: header.FileInfo(),
+			}
+			content, err := readArchiveEntry(tr, header.Size, maxSize)
+			switch {
+			case errors.Is(err, ErrArchiveEntryTooLarge):
+				entry.err = err
+			case err != nil:
+				yield(archiveEntry{}, err)
+				return
+			default:
+				entry.content = content
+			}
+			if !yield(entry, nil) {
+				return
+			}
+		}
+	}
+}
+
+func readZip(r io.Reader, maxSize int64) iter.Seq2[archiveEntry, error] {
+	return func(yield func(archiveEntry, error) bool) {
+		ra, size, err := readerAt(r, maxSize)
+		if err != nil {
+			yield(archiveEntry{}, err)
+			return
+		}
+		zr, err := zip.NewReader(ra, size)
+		if err != nil {
+			yield(archiveEntry{}, err)
+			return
+		}
+		for _, x := range zr.File {
+			if !x.Mode().IsRegular() {
+				continue
+			}
+			entry := archiveEntry{
+				name: cleanArchiveName(x.Name),
+				info: x.FileInfo(),
+			}
+			content, err := readZipFile(x, maxSize)
+			switch {
+			case errors.Is(err, ErrArchiveEntryTooLarge):
+				entry.err = err
+			case err != nil:
+				yield(archiveEntry{}, err)
+				return
+			default:
+				entry.content = content
+			}
+			if !yield(entry, nil) {
+				return
+			}
+		}
+	}
+}
+
+// readZipFile reads the file up to maxSize bytes, the declared size is not trusted.
+func readZipFile(f *zip.File, maxSize int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	size := int64(f.UncompressedSize64)
+	if f.UncompressedSize64 > math.MaxInt64 {
+		size = math.MaxInt64
+	}
+	return readArchiveEntry(rc, size, maxSize)
+}
+
+// readerAt returns r as io.ReaderAt, reads the whole content up to maxSize bytes unless r is a file.
+func readerAt(r io.Reader, maxSize int64) (io.ReaderAt, int64, error) {
+	if f, ok := r.(*os.File); ok {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, 0, err
+		}
+		return f, info.Size(), nil
+	}
+	b, err := readArchiveEntry(r, 0, maxSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(b), int64(len(b)), nil
+}
+
+func cleanArchiveName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
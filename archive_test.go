@@ -0,0 +1,157 @@
+package grdep_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/berquerant/grdep"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newTestTarGzip(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gw)
+	if err := w.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     "./etc/",
+		Mode:     0755,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := w.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWalkerArchive(t *testing.T) {
+	root := t.TempDir()
+	vendor := newTestZip(t, map[string]string{
+		"lib/a.sh":  ". b.sh",
+		"lib/x.log": "ignored",
+	})
+	bundle := newTestTarGzip(t, map[string][]byte{
+		"./etc/app/start.sh": []byte("install nginx"),
+		"vendor.zip":         vendor,
+	})
+	if !assert.Nil(t, os.WriteFile(filepath.Join(root, "bundle.tar.gz"), bundle, 0644)) {
+		return
+	}
+	if !assert.Nil(t, os.WriteFile(filepath.Join(root, "broken.zip"), []byte("not a zip"), 0644)) {
+		return
+	}
+
+	type result struct {
+		path    string
+		content string
+		err     bool
+	}
+	newRegexp := func(pattern string) *grdep.Regexp {
+		v := grdep.NewRegexp(pattern)
+		return &v
+	}
+	walk := func(target string, depth int, opt ...grdep.WalkerOption) []result {
+		ignores := grdep.MatcherSet([]*grdep.Matcher{
+			{Regex: newRegexp(`\.log$`)},
+		})
+		got := []result{}
+		opt = append(opt, grdep.WithArchiveDepth(depth))
+		for f := range grdep.NewWalker(target, ignores, opt...).Walk(context.TODO()) {
+			rel, err := filepath.Rel(root, f.Path)
+			assert.Nil(t, err)
+			r := result{
+				path: filepath.ToSlash(rel),
+			}
+			for x := range f.Lines(context.TODO()) {
+				if x.Err != nil {
+					r.err = true
+					continue
+				}
+				r.content += x.Content
+			}
+			got = append(got, r)
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i].path < got[j].path })
+		return got
+	}
+
+	t.Run("nested", func(t *testing.T) {
+		assert.Equal(t, []result{
+			{path: "broken.zip", err: true},
+			{path: "bundle.tar.gz!/etc/app/start.sh", content: "install nginx"},
+			{path: "bundle.tar.gz!/vendor.zip!/lib/a.sh", content: ". b.sh"},
+		}, walk(root, 2))
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		got := walk(root, 1)
+		if !assert.Equal(t, 3, len(got)) {
+			return
+		}
+		assert.Equal(t, "bundle.tar.gz!/etc/app/start.sh", got[1].path)
+		assert.Equal(t, "bundle.tar.gz!/vendor.zip", got[2].path)
+	})
+
+	t.Run("root", func(t *testing.T) {
+		assert.Equal(t, []result{
+			{path: "bundle.tar.gz!/etc/app/start.sh", content: "install nginx"},
+		}, walk(filepath.Join(root, "bundle.tar.gz"), 1)[:1])
+	})
+
+	t.Run("max entry size", func(t *testing.T) {
+		assert.Equal(t, []result{
+			{path: "bundle.tar.gz!/etc/app/start.sh", content: "install nginx"},
+			{path: "bundle.tar.gz!/vendor.zip", err: true},
+		}, walk(filepath.Join(root, "bundle.tar.gz"), 2, grdep.WithArchiveMaxEntrySize(int64(len("install nginx")))))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		got := walk(root, 0)
+		assert.Equal(t, 2, len(got))
+		assert.Equal(t, "bundle.tar.gz", got[1].path)
+	})
+}
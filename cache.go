@@ -1,6 +1,8 @@
 package grdep
 
 import (
+	"sync"
+
 	"github.com/berquerant/cache"
 )
 
@@ -15,3 +17,24 @@ func CachedFunc[I comparable, O any](f func(I) O) func(I) O {
 		return v
 	}
 }
+
+type cachedValue[O any] struct {
+	once  sync.Once
+	value O
+}
+
+// CachedFuncByKey creates a function with in-memory cache using key(argument) as keys.
+// f is called once per key even if it is called concurrently.
+func CachedFuncByKey[K comparable, I, O any](key func(I) K, f func(I) O) func(I) O {
+	c, _ := cache.NewLRU(1000, func(K) (*cachedValue[O], error) {
+		return &cachedValue[O]{}, nil
+	})
+
+	return func(i I) O {
+		v, _ := c.Get(key(i))
+		v.once.Do(func() {
+			v.value = f(i)
+		})
+		return v.value
+	}
+}
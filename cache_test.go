@@ -53,3 +53,23 @@ func TestCachedFunc(t *testing.T) {
 	assert.Nil(t, eg.Wait())
 	assert.Equal(t, seedSize, int(called))
 }
+
+func TestCachedFuncByKey(t *testing.T) {
+	type arg struct {
+		key   string
+		value int
+	}
+	var (
+		called uint32
+		target = grdep.CachedFuncByKey(func(x arg) string {
+			return x.key
+		}, func(x arg) int {
+			atomic.AddUint32(&called, 1)
+			return x.value
+		})
+	)
+	assert.Equal(t, 1, target(arg{key: "a", value: 1}))
+	assert.Equal(t, 1, target(arg{key: "a", value: 2}))
+	assert.Equal(t, 3, target(arg{key: "b", value: 3}))
+	assert.Equal(t, 2, int(called))
+}
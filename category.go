@@ -1,26 +1,113 @@
 package grdep
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"iter"
 	"os"
+	"path/filepath"
 )
 
 type CategorySelectorIface interface {
-	Select(path string) ([]string, error)
+	Select(file *File) ([]string, error)
 	Close() error
 }
 
 var (
 	_ CategorySelectorIface = &FileCategorySelector{}
 	_ CategorySelectorIface = &TextCategorySelector{}
+	_ CategorySelectorIface = &StatCategorySelector{}
 )
 
+// NewFileCategorySelector selects categories by the path.
+// The path of a compressed file is without the compression suffix, see File.Name.
 func NewFileCategorySelector(matcher MatcherIface) CategorySelectorIface {
 	return &FileCategorySelector{
 		matcher: matcher,
+		kind:    "file",
+		target: func(f *File) (string, error) {
+			return f.Name(), nil
+		},
+	}
+}
+
+// NewBasenameCategorySelector selects categories by the name of the file.
+func NewBasenameCategorySelector(matcher MatcherIface) CategorySelectorIface {
+	return &FileCategorySelector{
+		matcher: matcher,
+		kind:    "basename",
+		target: func(f *File) (string, error) {
+			return filepath.Base(f.Name()), nil
+		},
+	}
+}
+
+// NewDirnameCategorySelector selects categories by the name of the parent directory.
+func NewDirnameCategorySelector(matcher MatcherIface) CategorySelectorIface {
+	return &FileCategorySelector{
+		matcher: matcher,
+		kind:    "dirname",
+		target: func(f *File) (string, error) {
+			return filepath.Base(filepath.Dir(f.Path)), nil
+		},
+	}
+}
+
+// NewMimeCategorySelector selects categories by the MIME type of the content like text/plain; charset=utf-8.
+func NewMimeCategorySelector(matcher MatcherIface) CategorySelectorIface {
+	return &FileCategorySelector{
+		matcher: matcher,
+		kind:    "mime",
+		target: func(f *File) (string, error) {
+			return f.ContentType()
+		},
+	}
+}
+
+// NewGitAttributeCategorySelector selects categories by the value of the attribute in .gitattributes,
+// e.g. linguist-language=Shell.
+// The value is "true" if the attribute is set, and files without the attribute are unmatched.
+// The category is the value as is if matcher is nil.
+// Files given by records are unmatched because they are not on the filesystem.
+func NewGitAttributeCategorySelector(attributes *GitAttributes, attr string, matcher MatcherIface) CategorySelectorIface {
+	return &FileCategorySelector{
+		matcher: matcher,
+		kind:    "gitattributes",
+		target: func(f *File) (string, error) {
+			if f.Record > 0 {
+				return "", ErrUnmatched
+			}
+			values, err := attributes.Get(f.Root, f.Path)
+			if err != nil {
+				return "", err
+			}
+			v, ok := values[attr]
+			if !ok || v == GitAttributeUnset {
+				return "", ErrUnmatched
+			}
+			return v, nil
+		},
+	}
+}
+
+// NewSymlinkCategorySelector selects categories by the target of the symlink.
+// Files other than symlinks are unmatched.
+func NewSymlinkCategorySelector(matcher MatcherIface) CategorySelectorIface {
+	return &FileCategorySelector{
+		matcher: matcher,
+		kind:    "symlink",
+		target: func(f *File) (string, error) {
+			info, err := f.Stat()
+			if err != nil {
+				return "", err
+			}
+			if info.Mode()&fs.ModeSymlink == 0 {
+				return "", ErrUnmatched
+			}
+			return os.Readlink(f.Path)
+		},
 	}
 }
 
@@ -32,6 +119,8 @@ func NewTextCategorySelector(reader *ReaderCategorySelector) CategorySelectorIfa
 
 type FileCategorySelector struct {
 	matcher MatcherIface
+	kind    string
+	target  func(*File) (string, error)
 }
 
 func (c FileCategorySelector) Close() error {
@@ -41,14 +130,51 @@ func (c FileCategorySelector) Close() error {
 	return c.matcher.Close()
 }
 
-func (c FileCategorySelector) Select(path string) ([]string, error) {
-	r, err := c.matcher.Match(path)
+func (c FileCategorySelector) Select(file *File) ([]string, error) {
+	target, err := c.target(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s category %s", err, c.kind, file.Path)
+	}
+	if c.matcher == nil {
+		return []string{target}, nil
+	}
+	r, err := c.matcher.Match(target)
 	if err != nil {
-		return nil, fmt.Errorf("%w: file category %s", err, path)
+		return nil, fmt.Errorf("%w: %s category %s", err, c.kind, file.Path)
 	}
 	return r, nil
 }
 
+// NewStatCategorySelector selects categories by the selector if the metadata of the file matches the stat.
+func NewStatCategorySelector(stat FileStat, selector CategorySelectorIface) CategorySelectorIface {
+	return &StatCategorySelector{
+		stat:     stat,
+		selector: selector,
+	}
+}
+
+type StatCategorySelector struct {
+	stat     FileStat
+	selector CategorySelectorIface
+}
+
+func (s StatCategorySelector) Close() error {
+	return s.selector.Close()
+}
+
+func (s StatCategorySelector) Select(file *File) ([]string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("%w: stat category %s", err, file.Path)
+	}
+	// A broken symlink matches only the symlink condition.
+	target, _ := file.TargetStat()
+	if !s.stat.Match(info, target) {
+		return nil, fmt.Errorf("%w: stat category %s", ErrUnmatched, file.Path)
+	}
+	return s.selector.Select(file)
+}
+
 type TextCategorySelector struct {
 	reader *ReaderCategorySelector
 }
@@ -57,28 +183,46 @@ func (s *TextCategorySelector) Close() error {
 	return s.reader.Close()
 }
 
-func (s TextCategorySelector) Select(path string) ([]string, error) {
-	fp, err := os.Open(path)
+// Select reads the head of the file shared with other selectors.
+func (s TextCategorySelector) Select(file *File) ([]string, error) {
+	rs, err := s.reader.SelectLines(file.Head())
 	if err != nil {
-		return nil, fmt.Errorf("%w: text category %s", err, path)
+		return nil, fmt.Errorf("%w: text category %s", err, file.Path)
+	}
+	return rs, nil
+}
+
+type ReaderCategorySelectorOption func(*ReaderCategorySelector)
+
+// WithMaxLines limits the number of lines to read.
+func WithMaxLines(n int) ReaderCategorySelectorOption {
+	return func(s *ReaderCategorySelector) {
+		s.maxLines = n
 	}
-	defer fp.Close()
+}
 
-	rs, err := s.reader.Select(fp)
-	if err != nil {
-		return nil, fmt.Errorf("%w: text category %s", err, path)
+// WithMaxBytes limits the number of bytes to read, including line endings.
+// A line that exceeds the limit is not read.
+func WithMaxBytes(n int) ReaderCategorySelectorOption {
+	return func(s *ReaderCategorySelector) {
+		s.maxBytes = n
 	}
-	return rs, nil
 }
 
-func NewReaderCategorySelector(matcher MatcherIface) *ReaderCategorySelector {
-	return &ReaderCategorySelector{
+func NewReaderCategorySelector(matcher MatcherIface, opt ...ReaderCategorySelectorOption) *ReaderCategorySelector {
+	s := &ReaderCategorySelector{
 		matcher: matcher,
 	}
+	for _, f := range opt {
+		f(s)
+	}
+	return s
 }
 
 type ReaderCategorySelector struct {
-	matcher MatcherIface
+	matcher  MatcherIface
+	maxLines int // 0 means no limit
+	maxBytes int // 0 means no limit
 }
 
 func (s ReaderCategorySelector) Close() error {
@@ -86,10 +230,28 @@ func (s ReaderCategorySelector) Close() error {
 }
 
 func (s ReaderCategorySelector) Select(r io.Reader) ([]string, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	return s.SelectLines(NewFile("", nil, func() (io.ReadCloser, error) {
+		return io.NopCloser(r), nil
+	}).Head())
+}
+
+// SelectLines returns the result of the first matched line.
+func (s ReaderCategorySelector) SelectLines(lines iter.Seq[ReadLinesResult]) ([]string, error) {
+	var readLines, readBytes int
+	defer func() {
+		AddMetricCount("category-read-lines", uint64(readLines))
+		AddMetricCount("category-read-bytes", uint64(readBytes))
+	}()
+
+	var blocked error // keep ErrExecNotAllowed to report the matcher is not executed
+	for x := range lines {
+		n := len(x.Text) + 1
+		if s.maxBytes > 0 && readBytes+n > s.maxBytes {
+			break
+		}
+		readLines++
+		readBytes += n
 
-	for x := range ReadLines(ctx, r) {
 		if err := x.Err; err != nil {
 			return nil, fmt.Errorf("%w: reader category", err)
 		}
@@ -98,10 +260,19 @@ func (s ReaderCategorySelector) Select(r io.Reader) ([]string, error) {
 			return r, nil
 		}
 		if errors.Is(err, ErrUnmatched) {
+			if errors.Is(err, ErrExecNotAllowed) {
+				blocked = err
+			}
+			if s.maxLines > 0 && readLines >= s.maxLines {
+				break
+			}
 			continue
 		}
 		return nil, fmt.Errorf("%w: reader category %s", err, x)
 	}
 
+	if blocked != nil {
+		return nil, fmt.Errorf("%w: reader category", blocked)
+	}
 	return nil, fmt.Errorf("%w: reader category", ErrUnmatched)
 }
@@ -3,7 +3,10 @@ package grdep_test
 import (
 	"bytes"
 	"io"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/berquerant/grdep"
 	"github.com/stretchr/testify/assert"
@@ -14,6 +17,7 @@ func TestReaderCategorySelector(t *testing.T) {
 		name    string
 		r       io.Reader
 		matcher grdep.MatcherIface
+		opt     []grdep.ReaderCategorySelectorOption
 		want    []string
 		err     error
 	}{
@@ -56,9 +60,50 @@ func TestReaderCategorySelector(t *testing.T) {
 			}),
 			want: []string{"matched"},
 		},
+		{
+			name: "second line beyond max lines",
+			r:    bytes.NewBufferString("a\nb"),
+			matcher: MockMatcherFunc(func() func() ([]string, error) {
+				var i int
+				return func() ([]string, error) {
+					i++
+					if i == 2 {
+						return []string{"matched"}, nil
+					}
+					return nil, grdep.ErrUnmatched
+				}
+			}()),
+			opt: []grdep.ReaderCategorySelectorOption{grdep.WithMaxLines(1)},
+			err: grdep.ErrUnmatched,
+		},
+		{
+			name: "second line within max bytes",
+			r:    bytes.NewBufferString("a\nb"),
+			matcher: MockMatcherFunc(func() func() ([]string, error) {
+				var i int
+				return func() ([]string, error) {
+					i++
+					if i == 2 {
+						return []string{"matched"}, nil
+					}
+					return nil, grdep.ErrUnmatched
+				}
+			}()),
+			opt:  []grdep.ReaderCategorySelectorOption{grdep.WithMaxBytes(4)},
+			want: []string{"matched"},
+		},
+		{
+			name: "second line beyond max bytes",
+			r:    bytes.NewBufferString("a\nbc"),
+			matcher: MockMatcherFunc(func() ([]string, error) {
+				return nil, grdep.ErrUnmatched
+			}),
+			opt: []grdep.ReaderCategorySelectorOption{grdep.WithMaxBytes(4)},
+			err: grdep.ErrUnmatched,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			s := grdep.NewReaderCategorySelector(tc.matcher)
+			s := grdep.NewReaderCategorySelector(tc.matcher, tc.opt...)
 			defer s.Close()
 			got, err := s.Select(tc.r)
 			if tc.err != nil {
@@ -70,3 +115,193 @@ func TestReaderCategorySelector(t *testing.T) {
 		})
 	}
 }
+
+func TestFileCategorySelector(t *testing.T) {
+	dir := t.TempDir()
+	var (
+		script = filepath.Join(dir, "bin", "run")
+		link   = filepath.Join(dir, "link")
+	)
+	if !assert.Nil(t, os.MkdirAll(filepath.Dir(script), 0755)) {
+		return
+	}
+	if !assert.Nil(t, os.WriteFile(script, []byte("echo run"), 0755)) {
+		return
+	}
+	if !assert.Nil(t, os.Symlink(script, link)) {
+		return
+	}
+	var (
+		data       = filepath.Join(dir, "data")
+		dataLink   = filepath.Join(dir, "data-link")
+		brokenLink = filepath.Join(dir, "broken")
+		empty      = filepath.Join(dir, "empty")
+	)
+	if !assert.Nil(t, os.WriteFile(data, []byte("data"), 0644)) {
+		return
+	}
+	if !assert.Nil(t, os.Symlink(data, dataLink)) {
+		return
+	}
+	if !assert.Nil(t, os.Symlink(filepath.Join(dir, "missing"), brokenLink)) {
+		return
+	}
+	if !assert.Nil(t, os.WriteFile(empty, nil, 0644)) {
+		return
+	}
+
+	newRegexp := func(pattern string) *grdep.Regexp {
+		v := grdep.NewRegexp(pattern)
+		return &v
+	}
+	matcher := grdep.MatcherSet([]*grdep.Matcher{
+		{
+			Regex:    newRegexp(`(?P<v>.+)`),
+			Template: "$v",
+		},
+	})
+	var (
+		yes  = true
+		no   = false
+		past = time.Now().Add(-time.Hour)
+		size = func(n int64) *int64 { return &n }
+	)
+
+	for _, tc := range []struct {
+		name     string
+		selector grdep.CategorySelectorIface
+		path     string
+		want     []string
+		err      error
+	}{
+		{
+			name:     "basename",
+			selector: grdep.NewBasenameCategorySelector(matcher),
+			path:     script,
+			want:     []string{"run"},
+		},
+		{
+			name:     "dirname",
+			selector: grdep.NewDirnameCategorySelector(matcher),
+			path:     script,
+			want:     []string{"bin"},
+		},
+		{
+			name:     "symlink",
+			selector: grdep.NewSymlinkCategorySelector(matcher),
+			path:     link,
+			want:     []string{script},
+		},
+		{
+			name:     "not symlink",
+			selector: grdep.NewSymlinkCategorySelector(matcher),
+			path:     script,
+			err:      grdep.ErrUnmatched,
+		},
+		{
+			name: "stat matched",
+			selector: grdep.NewStatCategorySelector(grdep.FileStat{
+				Executable:    &yes,
+				Symlink:       &no,
+				MinSize:       size(1),
+				MaxSize:       size(100),
+				ModifiedAfter: past,
+			}, grdep.NewBasenameCategorySelector(matcher)),
+			path: script,
+			want: []string{"run"},
+		},
+		{
+			name: "stat too large",
+			selector: grdep.NewStatCategorySelector(grdep.FileStat{
+				MaxSize: size(1),
+			}, grdep.NewBasenameCategorySelector(matcher)),
+			path: script,
+			err:  grdep.ErrUnmatched,
+		},
+		{
+			name: "stat empty",
+			selector: grdep.NewStatCategorySelector(grdep.FileStat{
+				MaxSize: size(0),
+			}, grdep.NewBasenameCategorySelector(matcher)),
+			path: empty,
+			want: []string{"empty"},
+		},
+		{
+			name: "stat not empty",
+			selector: grdep.NewStatCategorySelector(grdep.FileStat{
+				MaxSize: size(0),
+			}, grdep.NewBasenameCategorySelector(matcher)),
+			path: script,
+			err:  grdep.ErrUnmatched,
+		},
+		{
+			name: "stat modified before",
+			selector: grdep.NewStatCategorySelector(grdep.FileStat{
+				ModifiedBefore: past,
+			}, grdep.NewBasenameCategorySelector(matcher)),
+			path: script,
+			err:  grdep.ErrUnmatched,
+		},
+		{
+			name: "stat symlink",
+			selector: grdep.NewStatCategorySelector(grdep.FileStat{
+				Symlink: &yes,
+			}, grdep.NewBasenameCategorySelector(matcher)),
+			path: link,
+			want: []string{"link"},
+		},
+		{
+			name: "stat symlink to executable",
+			selector: grdep.NewStatCategorySelector(grdep.FileStat{
+				Executable: &yes,
+				Symlink:    &yes,
+				MinSize:    size(int64(len("echo run"))),
+				MaxSize:    size(int64(len("echo run"))),
+			}, grdep.NewBasenameCategorySelector(matcher)),
+			path: link,
+			want: []string{"link"},
+		},
+		{
+			name: "stat symlink to not executable",
+			selector: grdep.NewStatCategorySelector(grdep.FileStat{
+				Executable: &no,
+			}, grdep.NewBasenameCategorySelector(matcher)),
+			path: dataLink,
+			want: []string{"data-link"},
+		},
+		{
+			name: "stat symlink to not executable is not executable",
+			selector: grdep.NewStatCategorySelector(grdep.FileStat{
+				Executable: &yes,
+			}, grdep.NewBasenameCategorySelector(matcher)),
+			path: dataLink,
+			err:  grdep.ErrUnmatched,
+		},
+		{
+			name: "stat broken symlink",
+			selector: grdep.NewStatCategorySelector(grdep.FileStat{
+				Symlink: &yes,
+			}, grdep.NewBasenameCategorySelector(matcher)),
+			path: brokenLink,
+			want: []string{"broken"},
+		},
+		{
+			name: "stat broken symlink has no target",
+			selector: grdep.NewStatCategorySelector(grdep.FileStat{
+				Executable: &no,
+			}, grdep.NewBasenameCategorySelector(matcher)),
+			path: brokenLink,
+			err:  grdep.ErrUnmatched,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.selector.Select(grdep.OpenFile(tc.path, nil))
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
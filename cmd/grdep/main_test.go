@@ -3,6 +3,7 @@ package main_test
 import (
 	"encoding/json"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -63,12 +64,160 @@ func TestEndToEnd(t *testing.T) {
 		}
 
 		var out strings.Builder
-		cmd := exec.Command(bin, "run", config)
+		cmd := exec.Command(bin, "run", config, "--allow-exec")
 		cmd.Stdin = strings.NewReader(input)
 		cmd.Stdout = &out
 		fail(t, cmd.Run())
 		got := tidy(out.String())
 		assert.Equal(t, want, got)
+
+		t.Run("jobs", func(t *testing.T) {
+			runJobs := func(arg ...string) string {
+				var out strings.Builder
+				cmd := exec.Command(bin, append([]string{"run", config, "--allow-exec", "-j", "4"}, arg...)...)
+				cmd.Stdin = strings.NewReader(input)
+				cmd.Stdout = &out
+				fail(t, cmd.Run())
+				return out.String()
+			}
+
+			t.Run("ordered", func(t *testing.T) {
+				assert.Equal(t, out.String(), runJobs())
+			})
+
+			t.Run("unordered", func(t *testing.T) {
+				assert.Equal(t, want, tidy(runJobs("--unordered")))
+			})
+		})
+
+		t.Run("input", func(t *testing.T) {
+			runInput := func(stdin string, arg ...string) string {
+				var out strings.Builder
+				cmd := exec.Command(bin, append([]string{"run", config, "--allow-exec"}, arg...)...)
+				cmd.Stdin = strings.NewReader(stdin)
+				cmd.Stdout = &out
+				fail(t, cmd.Run())
+				return out.String()
+			}
+
+			t.Run("positional", func(t *testing.T) {
+				assert.Equal(t, out.String(), runInput("", "--", input))
+			})
+
+			t.Run("null", func(t *testing.T) {
+				assert.Equal(t, out.String(), runInput(input+"\x00", "-0"))
+			})
+
+			t.Run("files-from", func(t *testing.T) {
+				paths := filepath.Join(based, "paths")
+				fail(t, os.WriteFile(paths, []byte(input+"\n"), 0644))
+				assert.Equal(t, out.String(), runInput("", "--files-from", paths))
+			})
+
+			t.Run("jsonl", func(t *testing.T) {
+				got := tidy(runInput(`{"path":"`+input+`","label":"target"}`, "--jsonl"))
+				for _, x := range got {
+					r := x.(map[string]any)
+					assert.Equal(t, "target", r["label"])
+					delete(r, "label")
+				}
+				assert.Equal(t, want, got)
+			})
+
+			t.Run("paths with reader flags", func(t *testing.T) {
+				for _, arg := range [][]string{
+					{"-0"},
+					{"--jsonl"},
+					{"--files-from", filepath.Join(based, "paths")},
+				} {
+					cmd := exec.Command(bin, append(append([]string{"run", config, "--allow-exec"}, arg...), "--", input)...)
+					assert.NotNil(t, cmd.Run(), arg)
+				}
+			})
+
+			t.Run("jsonl max depth", func(t *testing.T) {
+				got := tidy(runInput(`{"path":"`+input+`","max_depth":1}`, "--jsonl"))
+				assert.NotEmpty(t, got)
+				for _, x := range got {
+					path := x.(map[string]any)["line"].(map[string]any)["path"].(string)
+					assert.Equal(t, input, filepath.Dir(path))
+				}
+			})
+		})
+
+		t.Run("records", func(t *testing.T) {
+			const path = input + "/a.sh"
+			content, err := os.ReadFile(path)
+			fail(t, err)
+			record, err := json.Marshal(map[string]string{
+				"path":    path,
+				"content": string(content),
+			})
+			fail(t, err)
+
+			var out strings.Builder
+			cmd := exec.Command(bin, "run", config, "--allow-exec", "--records")
+			cmd.Stdin = strings.NewReader(string(record))
+			cmd.Stdout = &out
+			fail(t, cmd.Run())
+
+			wantRecords := []any{}
+			for _, x := range want {
+				r := maps.Clone(x.(map[string]any))
+				if r["line"].(map[string]any)["path"] == path {
+					r["path"] = map[string]any{"linum": float64(1), "text": "-"}
+					wantRecords = append(wantRecords, r)
+				}
+			}
+			assert.NotEmpty(t, wantRecords)
+			assert.Equal(t, wantRecords, tidy(out.String()))
+
+			t.Run("with walk flags", func(t *testing.T) {
+				for _, arg := range [][]string{
+					{"--gitignore"},
+					{"--image"},
+					{"--archive-depth", "1"},
+				} {
+					cmd := exec.Command(bin, append([]string{"run", config, "--allow-exec", "--records"}, arg...)...)
+					cmd.Stdin = strings.NewReader(string(record))
+					assert.NotNil(t, cmd.Run(), arg)
+				}
+			})
+		})
+
+		t.Run("sandbox", func(t *testing.T) {
+			allowlist := filepath.Join(based, "allowlist")
+			runSandbox := func() string {
+				var out strings.Builder
+				cmd := exec.Command(bin, "run", config, "--allowlist", allowlist)
+				cmd.Stdin = strings.NewReader(input)
+				cmd.Stdout = &out
+				fail(t, cmd.Run())
+				return out.String()
+			}
+
+			t.Run("blocked", func(t *testing.T) {
+				got := runSandbox()
+				assert.Contains(t, got, `"name":"create bash node"`)
+				// the nodes of the blocked sh matcher are reported as errors
+				var blocked int
+				for _, x := range tidy(got) {
+					r := x.(map[string]any)
+					if r["node"].(map[string]any)["origin"].(map[string]any)["name"] != "install" {
+						continue
+					}
+					blocked++
+					assert.Contains(t, r["err"], "ExecNotAllowed")
+					assert.Nil(t, r["node"].(map[string]any)["origin"].(map[string]any)["result"])
+				}
+				assert.NotZero(t, blocked)
+			})
+
+			t.Run("trusted", func(t *testing.T) {
+				fail(t, run(bin, "trust", config, "--allowlist", allowlist))
+				assert.Equal(t, want, tidy(runSandbox()))
+			})
+		})
 	})
 }
 
@@ -13,4 +13,4 @@ cfg="$(mktemp).yml"
 
 cd "${d}/.."
 "$grdep" skeleton > "$cfg"
-echo "$target" | "$grdep" run "$cfg" "$@"
+echo "$target" | "$grdep" run "$cfg" --allow-exec "$@"
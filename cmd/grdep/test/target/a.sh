@@ -5,3 +5,5 @@ echo "a.sh"
 /usr/bin/ls
 /usr/local/src/app
 /local/src/app
+. c.sh # load c
+# . old.sh
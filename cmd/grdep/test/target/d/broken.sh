@@ -0,0 +1 @@
+missing.sh
\ No newline at end of file
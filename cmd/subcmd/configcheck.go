@@ -17,7 +17,7 @@ var configCheckCmd = &cobra.Command{
 	Use:   "configcheck FILE_OR_TEXT [FILE_OR_TEXT] [--json]",
 	Short: "Test configurations",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		config, err := parseConfigs(args)
+		config, err := parseConfigs(args, nil)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,106 @@
+package subcmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/berquerant/grdep"
+)
+
+// rootInput is a root path to search.
+type rootInput struct {
+	Path  grdep.ReadLinesResult
+	Label string
+	// Config overrides the configs of the command if not empty.
+	Config []string
+	// MaxDepth overrides --max-depth if not nil.
+	MaxDepth *int
+}
+
+// jsonRoot is a record of --jsonl input.
+type jsonRoot struct {
+	Path     string   `json:"path"`
+	Label    string   `json:"label,omitempty"`
+	Config   []string `json:"config,omitempty"`
+	MaxDepth *int     `json:"max_depth,omitempty"`
+}
+
+var errInvalidInput = errors.New("InvalidInput")
+
+func (r jsonRoot) intoRoot(linum int) rootInput {
+	return rootInput{
+		Path: grdep.ReadLinesResult{
+			Linum: linum,
+			Text:  r.Path,
+		},
+		Label:    r.Label,
+		Config:   r.Config,
+		MaxDepth: r.MaxDepth,
+	}
+}
+
+// rootReader reads the root paths from the arguments or the reader.
+type rootReader struct {
+	// paths are the arguments, the reader is not read if not empty.
+	paths []string
+	r     io.Reader
+	// null separates paths by NUL instead of newline.
+	null bool
+	// jsonl reads a jsonRoot per record.
+	jsonl bool
+}
+
+func (x rootReader) read(ctx context.Context) iter.Seq[rootInput] {
+	return func(yield func(rootInput) bool) {
+		if len(x.paths) > 0 {
+			for i, p := range x.paths {
+				if !yield(rootInput{
+					Path: grdep.ReadLinesResult{
+						Linum: i + 1,
+						Text:  p,
+					},
+				}) {
+					return
+				}
+			}
+			return
+		}
+
+		records := grdep.ReadLines
+		if x.null {
+			records = grdep.ReadNullSeparated
+		}
+		for record := range records(ctx, x.r) {
+			if !yield(x.parse(record)) {
+				return
+			}
+		}
+	}
+}
+
+func (x rootReader) parse(record grdep.ReadLinesResult) rootInput {
+	if !x.jsonl || record.Err != nil {
+		return rootInput{
+			Path: record,
+		}
+	}
+
+	var v jsonRoot
+	if err := json.Unmarshal([]byte(record.Text), &v); err != nil {
+		record.Err = fmt.Errorf("%w: %w: record %d", errInvalidInput, err, record.Linum)
+		return rootInput{
+			Path: record,
+		}
+	}
+	if v.Path == "" {
+		record.Err = fmt.Errorf("%w: path is required: record %d", errInvalidInput, record.Linum)
+		return rootInput{
+			Path: record,
+		}
+	}
+	return v.intoRoot(record.Linum)
+}
@@ -3,37 +3,57 @@ package subcmd
 import "github.com/berquerant/grdep"
 
 type Result struct {
+	// Label is the label of the input path.
+	Label    string                `json:"label,omitempty"`
 	Path     grdep.ReadLinesResult `json:"path,omitempty"`
 	Line     grdep.Line            `json:"line,omitempty"`
 	Category Selected              `json:"category,omitempty"`
 	Node     Selected              `json:"node,omitempty"`
+	// Vars are the variables of the file like project_root.
+	Vars map[string]string `json:"vars,omitempty"`
+	// Err is the error of the file, the file is not scanned any more,
+	// or ExecNotAllowed of the category or the node of the matcher blocked by the sandbox.
+	Err string `json:"err,omitempty"`
 }
 
 type Selected struct {
 	Origin     grdep.NamedSelectorResult   `json:"origin,omitempty"`
 	Normalized grdep.NamedNormalizerResult `json:"normalized,omitempty"`
+	// Agreed is the origins that result in the same normalized value, including Origin.
+	Agreed []grdep.NamedSelectorResult `json:"agreed,omitempty"`
 }
 
 type PassArg struct {
+	Label              string
 	Path               grdep.ReadLinesResult
 	Line               grdep.Line
 	Category           grdep.NamedSelectorResult
 	NormalizedCategory grdep.NamedNormalizerResult
+	AgreedCategories   []grdep.NamedSelectorResult
 	Node               grdep.NamedSelectorResult
 	NormalizedNode     grdep.NamedNormalizerResult
+	Vars               map[string]string
+	Err                error
 }
 
 func (p PassArg) intoResult() Result {
-	return Result{
-		Path: p.Path,
-		Line: p.Line,
+	r := Result{
+		Label: p.Label,
+		Path:  p.Path,
+		Line:  p.Line,
 		Category: Selected{
 			Origin:     p.Category,
 			Normalized: p.NormalizedCategory,
+			Agreed:     p.AgreedCategories,
 		},
 		Node: Selected{
 			Origin:     p.Node,
 			Normalized: p.NormalizedNode,
 		},
+		Vars: p.Vars,
 	}
+	if p.Err != nil {
+		r.Err = p.Err.Error()
+	}
+	return r
 }
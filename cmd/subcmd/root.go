@@ -77,7 +77,9 @@ var (
 	errNoConfigFiles   = errors.New("NoConfigFiles")
 )
 
-func parseConfigs(configs []string) (*grdep.Config, error) {
+// parseConfigs parses and merges configs.
+// If trust is not nil, untrusted configs are sandboxed.
+func parseConfigs(configs []string, trust *trust) (*grdep.Config, error) {
 	if len(configs) == 0 {
 		return nil, errNoConfigFiles
 	}
@@ -88,6 +90,9 @@ func parseConfigs(configs []string) (*grdep.Config, error) {
 		if err != nil {
 			return nil, fmt.Errorf("%w: config[%d] %s", err, i, config)
 		}
+		if trust != nil {
+			trust.apply(c, config)
+		}
 		result = result.Add(*c)
 	}
 	return &result, nil
@@ -1,7 +1,12 @@
 package subcmd
 
 import (
+	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/berquerant/grdep"
 	"github.com/spf13/cobra"
@@ -9,6 +14,31 @@ import (
 
 func init() {
 	runCmd.Flags().BoolP("category", "C", false, "Determine category and exit")
+	runCmd.Flags().Bool("binary", false, "Scan binary files, they are skipped by default")
+	runCmd.Flags().IntP("jobs", "j", 1, "Number of files to process concurrently")
+	runCmd.Flags().Bool("unordered", false, `Write results of files as soon as they are processed with --jobs.
+By default results are written in the order of input paths, files and lines.`)
+	runCmd.Flags().Bool("follow-symlinks", false, `Descend into symlinked directories.
+Files reached through several paths are scanned once, results have the resolved path as real_path.`)
+	runCmd.Flags().Int("archive-depth", 0, `Scan files in archives (tar, tar.gz, tgz, tar.bz2, zip, jar, war) up to the depth of nesting.
+Files in archives have paths like bundle.tar.gz!/etc/app/start.sh, 0 means archives are not scanned.`)
+	runCmd.Flags().Int64("archive-max-entry-size", grdep.DefaultArchiveMaxEntrySize, `Max bytes of a file in archives and images to read into memory.
+Larger files are reported as errors and not scanned, 0 means no limit.`)
+	runCmd.Flags().Bool("image", false, `Read paths as container images: docker save tarballs or OCI image layout directories.
+Files of the merged filesystem have paths like image.tar!/usr/bin/app, results have the digest of the layer as layer.`)
+	runCmd.Flags().Int("max-depth", -1, "Descend at most the levels of directories below the paths like find -maxdepth, negative means no limit")
+	runCmd.Flags().BoolP("null", "0", false, "Separate input paths by NUL instead of newline, like find -print0")
+	runCmd.Flags().String("files-from", "", "Read input paths or records from the file instead of standard input")
+	runCmd.Flags().Bool("jsonl", false, "Read input paths as JSON records with path, label, config and max_depth")
+	runCmd.Flags().Bool("records", false, `Read input as JSON records of files like {"path":"ci/build.log","content":"..."} or {"path":"x.sh","lines":["..."]}.
+The files are scanned without touching the filesystem, results have - as the input path.
+marker and gitattributes categories do not match them, ignore_gitattributes is not applied and --gitignore, --image and --archive-depth cannot be used.`)
+	runCmd.Flags().Int("max-line-length", grdep.DefaultMaxLineLength, "Max line length in bytes")
+	runCmd.Flags().String("long-line", grdep.LongLineTruncate, "How to read lines longer than --max-line-length: truncate or skip")
+	runCmd.Flags().Bool("gitignore", false, `Skip files and directories ignored by .gitignore, .ignore and .git/info/exclude.
+The .git directories are also skipped. Outside git repositories, the files above the root are not read.`)
+	runCmd.Flags().Bool("allow-exec", false, `Allow sh matchers and lua with os and io libraries in all configs.
+By default, they are allowed only in configs in the allowlist.`)
 	runCmd.Flags().String("profile.name", "", `Enable profiling.
 cpu, goroutine, heap, threadcreate, block, mutex are available.
 See https://pkg.go.dev/runtime/pprof#Profile`)
@@ -17,9 +47,29 @@ See https://pkg.go.dev/runtime/pprof#Profile`)
 }
 
 var runCmd = &cobra.Command{
-	Use:   "run FILE_OR_TEXT [FILE_OR_TEXT]",
+	Use:   "run FILE_OR_TEXT [FILE_OR_TEXT] [-- PATH...]",
 	Short: "Find dependencies",
-	Long:  "Find dependencies. Treat each line of standard input as a path to search.",
+	Long: `Find dependencies.
+
+Paths to search are the arguments after --, or read from --files-from or standard input.
+Each line of the input is a path by default, -0 separates paths by NUL like find -print0.
+-0, --files-from and --jsonl cannot be used with the arguments after --.
+With --jsonl, each record is a JSON like
+
+  {"path":"src","label":"app","config":["app.yml"],"max_depth":2}
+
+label is copied into the results of the path,
+config overrides the configs of the arguments, max_depth overrides --max-depth.
+
+With --records, each record is a file given by the content like
+
+  {"path":"ci/build.log","content":"..."}
+  {"path":"db/scripts/1.sh","lines":["#!/bin/bash","..."]}
+
+Configs are sandboxed unless --allow-exec is passed or they are in the allowlist:
+sh matchers are blocked and lua matchers are loaded without os and io libraries.
+The results of blocked sh matchers have ExecNotAllowed as err.
+See trust command.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if name, _ := cmd.Flags().GetString("profile.name"); name != "" {
 			dir, _ := cmd.Flags().GetString("profile.dir")
@@ -35,69 +85,310 @@ var runCmd = &cobra.Command{
 			defer stop()
 		}
 
-		logger := getLogger(cmd, os.Stderr)
-		config, err := parseConfigs(args)
+		configs, paths := args, []string(nil)
+		if i := cmd.ArgsLenAtDash(); i >= 0 {
+			configs, paths = args[:i], args[i:]
+		}
+
+		trust, err := newTrust(cmd)
 		if err != nil {
 			return err
 		}
-		var (
-			ignores             = grdep.NewNamedMatcherSet("ignore", grdep.MatcherSet(config.Ignores))
-			categories          = newNamedCategorySelectors(config.Categories)
-			nodes               = newNamedNodeSelectors(config.Nodes)
-			categoryNormalizers = newNamedNormalizers(config.Normalizers.Categories)
-			nodeNormalizers     = newNamedNormalizers(config.Normalizers.Nodes)
-			isDebug             = getDebug(cmd)
-			categoryOnly, _     = cmd.Flags().GetBool("category")
-		)
-
-		defer func() {
-			_ = categories.Close()
-			_ = nodes.Close()
-			_ = categoryNormalizers.Close()
-			_ = nodeNormalizers.Close()
-		}()
-
-		r := runner{
-			config:     config,
-			r:          os.Stdin,
-			w:          os.Stdout,
-			logger:     logger,
-			isDebug:    isDebug,
-			ignores:    ignores,
-			categories: grdep.CachedFunc(categories.Select),
-			// Caching lines as keys is not very effective
-			nodes:              nodes.Select,
-			categoryNormalizer: grdep.CachedFunc(categoryNormalizers.Normalize),
-			nodeNormalizer:     grdep.CachedFunc(nodeNormalizers.Normalize),
-			categoryOnly:       categoryOnly,
+		input, closeInput, err := newRootReader(cmd, paths)
+		if err != nil {
+			return err
+		}
+		defer closeInput()
+
+		f := &runnerFactory{
+			cmd:    cmd,
+			trust:  trust,
+			logger: getLogger(cmd, os.Stderr),
+			cache:  map[string]runner{},
+		}
+		defer f.close()
+		r, err := f.newRunner(configs)
+		if err != nil {
+			return err
 		}
+		r.input = input
 		return r.run(cmd.Context())
 	},
 }
 
-func newCategorySelector(selector grdep.CSelector) grdep.CategorySelectorIface {
-	if selector.Filename != nil {
+func newRootReader(cmd *cobra.Command, paths []string) (rootReader, func(), error) {
+	var (
+		null, _      = cmd.Flags().GetBool("null")
+		jsonl, _     = cmd.Flags().GetBool("jsonl")
+		records, _   = cmd.Flags().GetBool("records")
+		filesFrom, _ = cmd.Flags().GetString("files-from")
+		r            = rootReader{
+			paths: paths,
+			r:     os.Stdin,
+			null:  null,
+			jsonl: jsonl,
+		}
+	)
+	if records && (len(paths) > 0 || null || jsonl) {
+		return r, nil, fmt.Errorf("%w: --records cannot be used with paths, -0 and --jsonl", errInvalidArgument)
+	}
+	if len(paths) > 0 && (filesFrom != "" || null || jsonl) {
+		return r, nil, fmt.Errorf("%w: paths cannot be used with --files-from, -0 and --jsonl", errInvalidArgument)
+	}
+	if filesFrom == "" {
+		return r, func() {}, nil
+	}
+	f, err := os.Open(filesFrom)
+	if err != nil {
+		return r, nil, err
+	}
+	r.r = f
+	return r, func() { _ = f.Close() }, nil
+}
+
+// runnerFactory builds runners from configs and the flags of the command.
+type runnerFactory struct {
+	cmd     *cobra.Command
+	trust   *trust
+	logger  *slog.Logger
+	cache   map[string]runner // runners by the configs
+	closers []func()
+}
+
+func (f *runnerFactory) close() {
+	for _, c := range f.closers {
+		c()
+	}
+}
+
+// withConfig returns the runner of the configs, it is built once per configs.
+func (f *runnerFactory) withConfig(configs []string) (runner, error) {
+	key := strings.Join(configs, "\x00")
+	if r, ok := f.cache[key]; ok {
+		return r, nil
+	}
+	r, err := f.newRunner(configs)
+	if err != nil {
+		return r, err
+	}
+	f.cache[key] = r
+	return r, nil
+}
+
+func (f *runnerFactory) newRunner(args []string) (runner, error) {
+	cmd := f.cmd
+	config, err := parseConfigs(args, f.trust)
+	if err != nil {
+		return runner{}, err
+	}
+	var (
+		ignores             = grdep.NewNamedMatcherSet("ignore", grdep.MatcherSet(config.Ignores))
+		attributes          = grdep.NewGitAttributes()
+		categories          = newNamedCategorySelectors(config.Categories, attributes)
+		nodes               = newNamedNodeSelectors(config.Nodes)
+		categoryNormalizers = newNamedNormalizers(config.Normalizers.Categories)
+		nodeNormalizers     = newNamedNormalizers(config.Normalizers.Nodes)
+		isDebug             = getDebug(cmd)
+		categoryOnly, _     = cmd.Flags().GetBool("category")
+		binary, _           = cmd.Flags().GetBool("binary")
+		gitignore, _        = cmd.Flags().GetBool("gitignore")
+		jobs, _             = cmd.Flags().GetInt("jobs")
+		followSymlinks, _   = cmd.Flags().GetBool("follow-symlinks")
+		archiveDepth, _     = cmd.Flags().GetInt("archive-depth")
+		archiveMaxEntry, _  = cmd.Flags().GetInt64("archive-max-entry-size")
+		maxDepth, _         = cmd.Flags().GetInt("max-depth")
+		image, _            = cmd.Flags().GetBool("image")
+		records, _          = cmd.Flags().GetBool("records")
+		maxLineLength, _    = cmd.Flags().GetInt("max-line-length")
+		longLine, _         = cmd.Flags().GetString("long-line")
+		unordered, _        = cmd.Flags().GetBool("unordered")
+	)
+
+	if err := grdep.ValidateLongLine(longLine); err != nil {
+		return runner{}, err
+	}
+	// workers use up to jobs lua states at a time
+	config.LimitLuaPool(jobs)
+	if records && gitignore {
+		return runner{}, fmt.Errorf("%w: --gitignore cannot be used with --records", errInvalidArgument)
+	}
+	if records && image {
+		return runner{}, fmt.Errorf("%w: --image cannot be used with --records", errInvalidArgument)
+	}
+	if records && archiveDepth > 0 {
+		return runner{}, fmt.Errorf("%w: --archive-depth cannot be used with --records", errInvalidArgument)
+	}
+
+	var (
+		fileNodes    func(*grdep.Scope, []grdep.Line) []grdep.NamedFileNodeResult
+		hasFileNodes func(string) bool
+		ancestors    = grdep.CachedFunc(config.Hierarchy.Ancestors)
+	)
+	if nodes.HasFileSelector() {
+		fileNodes = nodes.SelectFile
+		// decided once per category, lines are buffered only for the categories
+		hasFileNodes = grdep.CachedFunc(func(category string) bool {
+			scope := grdep.NewScope("", category)
+			defer scope.Close()
+			scope.Ancestors = ancestors(category)
+			return nodes.HasFileSelectorFor(scope)
+		})
+	}
+
+	var walkerOptions []grdep.WalkerOption
+	if archiveDepth > 0 {
+		walkerOptions = append(walkerOptions, grdep.WithArchiveDepth(archiveDepth))
+	}
+	walkerOptions = append(walkerOptions, grdep.WithArchiveMaxEntrySize(archiveMaxEntry))
+	if followSymlinks {
+		walkerOptions = append(walkerOptions, grdep.WithFollowSymlinks())
+	}
+	if maxDepth >= 0 {
+		walkerOptions = append(walkerOptions, grdep.WithMaxDepth(maxDepth))
+	}
+	// skips by the git files depend on the root because the files above it are not read outside repositories
+	var ignorer *grdep.GitIgnore
+	if gitignore {
+		ignorer = grdep.NewGitIgnore()
+	}
+	rootWalkerOptions := func(root string) []grdep.WalkerOption {
+		var xs []grdep.WalkerOption
+		// records are not on the filesystem
+		if len(config.IgnoreGitAttributes) > 0 && !records {
+			xs = append(xs, grdep.WithSkip(func(path string, info fs.FileInfo) bool {
+				return !info.IsDir() && attributes.Ignored(root, path, config.IgnoreGitAttributes)
+			}))
+		}
+		if ignorer != nil {
+			xs = append(xs, grdep.WithSkip(func(path string, info fs.FileInfo) bool {
+				return ignorer.Ignored(root, path, info.IsDir())
+			}))
+		}
+		return xs
+	}
+
+	f.closers = append(f.closers, func() {
+		_ = categories.Close()
+		_ = nodes.Close()
+		_ = categoryNormalizers.Close()
+		_ = nodeNormalizers.Close()
+	})
+
+	return runner{
+		config:            config,
+		w:                 os.Stdout,
+		logger:            f.logger,
+		isDebug:           isDebug,
+		ignores:           ignores,
+		walkerOptions:     walkerOptions,
+		rootWalkerOptions: rootWalkerOptions,
+		withConfig:        f.withConfig,
+		// records may have the same path with different contents
+		categories: grdep.CachedFuncByKey(func(f *grdep.File) fileKey { return fileKey{path: f.Path, record: f.Record} }, func(f *grdep.File) fileCategories {
+			return fileCategories{
+				results: categories.SelectMode(f, config.CategoryMode),
+				vars:    f.Vars,
+			}
+		}),
+		// Caching lines as keys is not very effective
+		nodes:              nodes.SelectScope,
+		categoryNormalizer: grdep.CachedFunc(categoryNormalizers.Normalize),
+		nodeNormalizer:     grdep.CachedFunc(nodeNormalizers.Normalize),
+		joiner:             config.Continuations.NewLineJoiner,
+		comment:            config.Comments.NewCommentFilter,
+		ancestors:          ancestors,
+		fileNodes:          fileNodes,
+		hasFileNodes:       hasFileNodes,
+		categoryOnly:       categoryOnly,
+		binary:             binary,
+		jobs:               jobs,
+		unordered:          unordered,
+		image:              image,
+		records:            records,
+		lineOptions: []grdep.LineReaderOption{
+			grdep.WithMaxLineLength(maxLineLength),
+			grdep.WithLongLine(longLine),
+		},
+	}, nil
+}
+
+func newCategorySelector(selector grdep.CSelector, now time.Time, attributes *grdep.GitAttributes) grdep.CategorySelectorIface {
+	s := newCategorySourceSelector(selector, attributes)
+	if selector.Stat != nil {
+		// validated
+		stat, _ := selector.Stat.FileStat(now)
+		return grdep.NewStatCategorySelector(stat, s)
+	}
+	return s
+}
+
+func newCategorySourceSelector(selector grdep.CSelector, attributes *grdep.GitAttributes) grdep.CategorySelectorIface {
+	switch {
+	case selector.Filename != nil:
 		return grdep.NewFileCategorySelector(grdep.MatcherSet(selector.Filename))
+	case selector.Basename != nil:
+		return grdep.NewBasenameCategorySelector(grdep.MatcherSet(selector.Basename))
+	case selector.Dirname != nil:
+		return grdep.NewDirnameCategorySelector(grdep.MatcherSet(selector.Dirname))
+	case selector.Symlink != nil:
+		return grdep.NewSymlinkCategorySelector(grdep.MatcherSet(selector.Symlink))
+	case selector.Mime != nil:
+		return grdep.NewMimeCategorySelector(grdep.MatcherSet(selector.Mime))
+	case selector.Marker != nil:
+		var matcher grdep.MatcherIface
+		if len(selector.Marker.Matcher) > 0 {
+			matcher = grdep.MatcherSet(selector.Marker.Matcher)
+		}
+		return grdep.NewMarkerCategorySelector(selector.Marker.Files, selector.Marker.Var, matcher)
+	case selector.GitAttributes != nil:
+		var matcher grdep.MatcherIface
+		if len(selector.GitAttributes.Matcher) > 0 {
+			matcher = grdep.MatcherSet(selector.GitAttributes.Matcher)
+		}
+		return grdep.NewGitAttributeCategorySelector(attributes, selector.GitAttributes.Attr, matcher)
 	}
+	maxLines, maxBytes := selector.ReadLimit()
 	return grdep.NewTextCategorySelector(
-		grdep.NewReaderCategorySelector(grdep.MatcherSet(selector.Text)),
+		grdep.NewReaderCategorySelector(
+			grdep.MatcherSet(selector.Text),
+			grdep.WithMaxLines(maxLines),
+			grdep.WithMaxBytes(maxBytes),
+		),
 	)
 }
 
-func newNamedCategorySelectors(categories []grdep.CSelector) grdep.NamedCategorySelectors {
-	selectors := make([]*grdep.NamedCategorySelector, len(categories))
+func newNamedCategorySelectors(categories []grdep.CSelector, attributes *grdep.GitAttributes) grdep.NamedCategorySelectors {
+	var (
+		selectors = make([]*grdep.NamedCategorySelector, len(categories))
+		now       = time.Now()
+	)
 	for i, x := range categories {
-		selectors[i] = grdep.NewNamedCategorySelector(x.Name, newCategorySelector(x))
+		selectors[i] = grdep.NewNamedCategorySelector(x.Name, newCategorySelector(x, now, attributes), grdep.WithCategoryPriority(x.Priority))
 	}
 	return grdep.NamedCategorySelectors(selectors)
 }
 
+func newNodeSelector(selector grdep.NSelector) grdep.NodeSelectorIface {
+	if selector.Mode == grdep.NodeModeFile {
+		var rest grdep.MatcherIface
+		if len(selector.Matcher) > 1 {
+			rest = grdep.MatcherSet(selector.Matcher[1:])
+		}
+		first := selector.Matcher[0]
+		return grdep.NewFileNodeSelector(selector.Category, *first.Regex, first.Template, rest)
+	}
+
+	var opts []grdep.NodeSelectorOption
+	if selector.Scope != nil {
+		opts = append(opts, grdep.WithBlock(*selector.Scope.Begin, *selector.Scope.End))
+	}
+	return grdep.NewNodeSelector(selector.Category, grdep.MatcherSet(selector.Matcher), opts...)
+}
+
 func newNamedNodeSelectors(nodes []grdep.NSelector) grdep.NamedNodeSelectors {
 	selectors := make([]*grdep.NamedNodeSelector, len(nodes))
 	for i, x := range nodes {
-		selectors[i] = grdep.NewNamedNodeSelector(
-			x.Name,
-			grdep.NewNodeSelector(x.Category, grdep.MatcherSet(x.Matcher)))
+		selectors[i] = grdep.NewNamedNodeSelector(x.Name, newNodeSelector(x))
 	}
 	return grdep.NamedNodeSelectors(selectors)
 }
@@ -1,13 +1,17 @@
 package subcmd
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"log/slog"
+	"slices"
+	"sync"
 
 	"github.com/berquerant/grdep"
+	"golang.org/x/sync/errgroup"
 )
 
 func jsonify(v any) string {
@@ -17,16 +21,42 @@ func jsonify(v any) string {
 
 type runner struct {
 	config             *grdep.Config
-	r                  io.Reader
+	input              rootReader
 	w                  io.Writer
 	logger             *slog.Logger
 	isDebug            bool
 	ignores            grdep.MatcherIface
-	categories         func(string) []grdep.NamedSelectorResult
-	nodes              func(category, content string) []grdep.NamedSelectorResult
+	walkerOptions      []grdep.WalkerOption
+	rootWalkerOptions  func(root string) []grdep.WalkerOption // options that depend on the root of the walk
+	withConfig         func(configs []string) (runner, error) // runner that overrides the configs
+	categories         func(*grdep.File) fileCategories
+	nodes              func(scope *grdep.Scope, content string) []grdep.NamedSelectorResult
+	fileNodes          func(scope *grdep.Scope, lines []grdep.Line) []grdep.NamedFileNodeResult // nil if no file mode selectors
+	hasFileNodes       func(category string) bool                                               // true if file mode selectors select nodes in the category
 	categoryNormalizer func(string) []grdep.NamedNormalizerResult
 	nodeNormalizer     func(string) []grdep.NamedNormalizerResult
+	joiner             func(category string) *grdep.LineJoiner
+	comment            func(category string) *grdep.CommentFilter
+	ancestors          func(category string) []string
 	categoryOnly       bool
+	binary             bool // scan binary files
+	jobs               int  // number of files processed concurrently
+	unordered          bool // write results of files as soon as they are processed
+	image              bool // paths are container images
+	records            bool // the input is records of files instead of paths
+	lineOptions        []grdep.LineReaderOption
+}
+
+// fileKey identifies a file to cache the categories.
+type fileKey struct {
+	path   string
+	record int // index of the record, 0 if the file is not a record
+}
+
+// fileCategories is the result of categorization of a file.
+type fileCategories struct {
+	results []grdep.NamedSelectorResult
+	vars    map[string]string // set by category selectors
 }
 
 func (r runner) debug(f func()) {
@@ -41,52 +71,312 @@ func (r runner) write(v any) {
 
 func (r runner) run(ctx context.Context) error {
 	r.debug(func() { r.logger.Debug("run") })
-	for path := range grdep.ReadLines(ctx, r.r) {
+	if r.jobs > 1 {
+		return r.runConcurrently(ctx)
+	}
+	return r.walk(ctx, func(r runner, arg PassArg, file *grdep.File) error {
+		return r.processFile(ctx, arg, file)
+	})
+}
+
+// recordsPath is the input path of the results of records.
+const recordsPath = "-"
+
+// walkFunc processes the file by the runner of the root of the file.
+type walkFunc func(runner, PassArg, *grdep.File) error
+
+// walk calls f for each file of the paths from the input in order.
+func (r runner) walk(ctx context.Context, f walkFunc) error {
+	if r.records {
 		a := PassArg{
-			Path: path,
+			Path: grdep.ReadLinesResult{
+				Linum: 1,
+				Text:  recordsPath,
+			},
+		}
+		return r.processWalker(ctx, a, grdep.NewRecordWalker(r.input.r, r.ignores, r.walkerOptions...), f)
+	}
+	for root := range r.input.read(ctx) {
+		a := PassArg{
+			Path:  root.Path,
+			Label: root.Label,
+		}
+		if err := a.Path.Err; err != nil {
+			return err
 		}
-		if err := r.processPath(ctx, a); err != nil {
+		x, err := r.forRoot(root)
+		if err != nil {
+			return err
+		}
+		if err := x.processPath(ctx, a, f); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (r runner) processPath(ctx context.Context, arg PassArg) error {
-	r.debug(func() { r.logger.Debug("process path", "arg", jsonify(arg)) })
-	if err := arg.Path.Err; err != nil {
-		return err
+// forRoot returns the runner that applies the overrides of the root.
+func (r runner) forRoot(root rootInput) (runner, error) {
+	x := r
+	if len(root.Config) > 0 {
+		y, err := r.withConfig(root.Config)
+		if err != nil {
+			return r, err
+		}
+		x = y
+	}
+	if root.MaxDepth != nil {
+		x.walkerOptions = append(slices.Clip(x.walkerOptions), grdep.WithMaxDepth(*root.MaxDepth))
 	}
+	return x, nil
+}
 
-	for line := range grdep.NewWalker(arg.Path.Text, r.ignores).Walk(ctx) {
+func (r runner) processPath(ctx context.Context, arg PassArg, f walkFunc) error {
+	r.debug(func() { r.logger.Debug("process path", "arg", jsonify(arg)) })
+	return r.processWalker(ctx, arg, r.newWalker(arg.Path.Text), f)
+}
+
+func (r runner) processWalker(ctx context.Context, arg PassArg, walker grdep.WalkerIface, f walkFunc) error {
+	for file := range walker.Walk(ctx) {
 		a := arg
-		a.Line = line
-		if err := r.processLine(ctx, a); err != nil {
+		a.Line = grdep.Line{
+			Path:     file.Path,
+			RealPath: file.RealPath,
+			Layer:    file.Layer,
+		}
+		if err := f(r, a, file); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
-func (r runner) processLine(ctx context.Context, arg PassArg) error {
-	r.debug(func() { r.logger.Debug("process line", "arg", jsonify(arg)) })
-	if err := arg.Line.Err; err != nil {
+func (r runner) newWalker(root string) grdep.WalkerIface {
+	opt := append(slices.Clip(r.walkerOptions), r.rootWalkerOptions(root)...)
+	if r.image {
+		return grdep.NewImageWalker(root, r.ignores, opt...)
+	}
+	return grdep.NewWalker(root, r.ignores, opt...)
+}
+
+// fileTask is a file processed by a worker.
+type fileTask struct {
+	r      runner // runner of the root
+	arg    PassArg
+	file   *grdep.File
+	result chan fileResult
+}
+
+// fileResult is the output of a file.
+type fileResult struct {
+	out []byte
+	err error
+}
+
+// runConcurrently processes files by workers.
+//
+// The output of each file is buffered and written in the order of the walk,
+// so it is the same as the sequential run.
+// If unordered, the output is written as soon as the file is processed.
+func (r runner) runConcurrently(ctx context.Context) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	var (
+		taskC = make(chan *fileTask, r.jobs)
+		// results in the order of the walk, it limits the number of buffered outputs
+		orderC = make(chan chan fileResult, r.jobs*4)
+		mux    sync.Mutex // for unordered writes
+		// an error of the walk does not cancel the files being processed, like the sequential run
+		walkErr error
+	)
+
+	eg.Go(func() error {
+		defer close(taskC)
+		defer close(orderC)
+		walkErr = r.walk(ctx, func(x runner, arg PassArg, file *grdep.File) error {
+			t := &fileTask{
+				r:      x,
+				arg:    arg,
+				file:   file,
+				result: make(chan fileResult, 1),
+			}
+			if !r.unordered {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case orderC <- t.result:
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case taskC <- t:
+				return nil
+			}
+		})
+		return nil
+	})
+
+	for range r.jobs {
+		eg.Go(func() error {
+			for t := range taskC {
+				var (
+					buf bytes.Buffer
+					w   = t.r
+				)
+				w.w = &buf
+				err := w.processFile(ctx, t.arg, t.file)
+				if !r.unordered {
+					t.result <- fileResult{
+						out: buf.Bytes(),
+						err: err,
+					}
+					continue
+				}
+
+				mux.Lock()
+				_, _ = r.w.Write(buf.Bytes())
+				mux.Unlock()
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	if !r.unordered {
+		eg.Go(func() error {
+			for resultC := range orderC {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case x := <-resultC:
+					_, _ = r.w.Write(x.out)
+					if x.err != nil {
+						return x.err
+					}
+				}
+			}
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	return walkErr
+}
+
+// fileScanner scans lines of a file in each category of the file.
+type fileScanner struct {
+	r      runner
+	scopes []*categoryScope
+	lines  []grdep.Line // whole content for file mode selectors
+	// fileNodes is true if any scope has file mode selectors, lines are buffered only then.
+	fileNodes bool
+}
+
+// categoryScope is a file in a normalized category.
+type categoryScope struct {
+	arg       PassArg
+	scope     *grdep.Scope
+	joiner    *grdep.LineJoiner    // nil if lines are not joined
+	comment   *grdep.CommentFilter // nil if comments are not filtered
+	fileNodes bool                 // true if file mode selectors select nodes in the category
+}
+
+func (f *fileScanner) close(ctx context.Context) error {
+	var errs []error
+	for _, s := range f.scopes {
+		if s.joiner != nil {
+			if line, ok := s.joiner.Flush(); ok {
+				a := s.arg
+				a.Line = line
+				errs = append(errs, f.r.processScope(ctx, a, s.scope))
+			}
+		}
+		if s.fileNodes && f.lines != nil {
+			errs = append(errs, f.r.processFileScope(ctx, s.arg, s.scope, f.lines))
+		}
+		errs = append(errs, s.scope.Close())
+	}
+	f.scopes = nil
+	f.lines = nil
+	return errors.Join(errs...)
+}
+
+// release closes the scopes without flushing the rest of the file, no-op after close.
+func (f *fileScanner) release() {
+	for _, s := range f.scopes {
+		_ = s.scope.Close()
+	}
+	f.scopes = nil
+	f.lines = nil
+}
+
+// processFile scans the file and writes an error result instead of returning an error
+// unless the context is done.
+func (r runner) processFile(ctx context.Context, arg PassArg, file *grdep.File) error {
+	err := r.scanFile(ctx, arg, file)
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 		return err
 	}
+	r.debug(func() { r.logger.Debug("file error", "arg", jsonify(arg), "err", err) })
+	grdep.AddMetricCount("file-error", 1)
+	arg.Err = err
+	r.write(arg.intoResult())
+	return nil
+}
+
+func (r runner) scanFile(ctx context.Context, arg PassArg, file *grdep.File) error {
+	r.debug(func() { r.logger.Debug("process file", "arg", jsonify(arg)) })
+	defer file.Close()
+	file.SetLineReaderOptions(r.lineOptions...)
+
+	if !r.binary {
+		// Errors are reported when reading lines.
+		if isBinary, _ := file.IsBinary(); isBinary {
+			r.debug(func() { r.logger.Debug("skip binary", "arg", jsonify(arg)) })
+			grdep.AddMetricCount("skip-binary-file", 1)
+			return nil
+		}
+	}
 
-	for _, x := range r.categories(arg.Line.Path) {
+	scanner := &fileScanner{
+		r: r,
+	}
+	// Release the scopes on errors, on_file_end is called and lua states are returned.
+	defer scanner.release()
+	categories := r.categories(file)
+	arg.Vars = categories.vars
+	for _, x := range categories.results {
 		a := arg
 		a.Category = x
-		if err := r.processCategory(ctx, a); err != nil {
+		if err := r.processCategory(ctx, a, scanner); err != nil {
 			return err
 		}
 	}
-	return nil
+	if len(scanner.scopes) == 0 {
+		// No need to read the rest of the file.
+		return nil
+	}
+
+	for line := range file.Lines(ctx) {
+		a := arg
+		a.Line = line
+		if err := scanner.processLine(ctx, a); err != nil {
+			return err
+		}
+	}
+	return scanner.close(ctx)
 }
 
-func (r runner) processCategory(ctx context.Context, arg PassArg) error {
+func (r runner) processCategory(ctx context.Context, arg PassArg, file *fileScanner) error {
 	r.debug(func() { r.logger.Debug("process category", "arg", jsonify(arg)) })
+	if errors.Is(arg.Category.Err, grdep.ErrExecNotAllowed) {
+		r.writeBlocked(arg, arg.Category.Err)
+		return nil
+	}
 	if errors.Is(arg.Category.Err, grdep.ErrUnmatched) {
 		return nil
 	}
@@ -97,21 +387,82 @@ func (r runner) processCategory(ctx context.Context, arg PassArg) error {
 	for _, x := range r.categoryNormalizer(arg.Category.Result) {
 		a := arg
 		a.NormalizedCategory = x
-		if err := r.processNormalizedCategory(ctx, a); err != nil {
+		if err := r.processNormalizedCategory(ctx, a, file); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (r runner) processNormalizedCategory(ctx context.Context, arg PassArg) error {
+func (r runner) processNormalizedCategory(_ context.Context, arg PassArg, file *fileScanner) error {
 	r.debug(func() { r.logger.Debug("process normalized category", "arg", jsonify(arg)) })
+	// Deduplicate the same normalized categories.
+	for _, s := range file.scopes {
+		if s.arg.NormalizedCategory.Result == arg.NormalizedCategory.Result {
+			s.arg.AgreedCategories = append(s.arg.AgreedCategories, arg.Category)
+			return nil
+		}
+	}
+
+	arg.AgreedCategories = []grdep.NamedSelectorResult{arg.Category}
+	scope := grdep.NewScope(arg.Line.Path, arg.NormalizedCategory.Result)
+	scope.Ancestors = r.ancestors(arg.NormalizedCategory.Result)
+	scope.Vars = arg.Vars
+	fileNodes := r.fileNodes != nil && r.hasFileNodes(arg.NormalizedCategory.Result)
+	file.scopes = append(file.scopes, &categoryScope{
+		arg:       arg,
+		scope:     scope,
+		joiner:    r.joiner(arg.NormalizedCategory.Result),
+		comment:   r.comment(arg.NormalizedCategory.Result),
+		fileNodes: fileNodes,
+	})
+	file.fileNodes = file.fileNodes || fileNodes
+	return nil
+}
+
+func (f *fileScanner) processLine(ctx context.Context, arg PassArg) error {
+	r := f.r
+	r.debug(func() { r.logger.Debug("process line", "arg", jsonify(arg)) })
+	if err := arg.Line.Err; err != nil {
+		return err
+	}
+	if f.fileNodes && !r.categoryOnly {
+		f.lines = append(f.lines, arg.Line)
+	}
+
+	for _, s := range f.scopes {
+		a := s.arg
+		a.Line = arg.Line
+		if s.comment != nil {
+			line, ok := s.comment.Filter(a.Line)
+			if !ok {
+				continue
+			}
+			a.Line = line
+		}
+		if s.joiner != nil {
+			line, ok := s.joiner.Join(a.Line)
+			if !ok {
+				continue
+			}
+			a.Line = line
+		}
+		if err := r.processScope(ctx, a, s.scope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r runner) processScope(ctx context.Context, arg PassArg, scope *grdep.Scope) error {
+	r.debug(func() { r.logger.Debug("process scope", "arg", jsonify(arg)) })
 	if r.categoryOnly {
 		r.write(arg.intoResult())
 		return nil
 	}
 
-	for _, x := range r.nodes(arg.NormalizedCategory.Result, arg.Line.Content) {
+	scope.Line = arg.Line
+	for _, x := range r.nodes(scope, arg.Line.Content) {
 		a := arg
 		a.Node = x
 		if err := r.processNode(ctx, a); err != nil {
@@ -121,8 +472,25 @@ func (r runner) processNormalizedCategory(ctx context.Context, arg PassArg) erro
 	return nil
 }
 
+func (r runner) processFileScope(ctx context.Context, arg PassArg, scope *grdep.Scope, lines []grdep.Line) error {
+	r.debug(func() { r.logger.Debug("process file scope", "arg", jsonify(arg)) })
+	for _, x := range r.fileNodes(scope, lines) {
+		a := arg
+		a.Line = x.Line
+		a.Node = x.Node
+		if err := r.processNode(ctx, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (r runner) processNode(ctx context.Context, arg PassArg) error {
 	r.debug(func() { r.logger.Debug("process node", "arg", jsonify(arg)) })
+	if errors.Is(arg.Node.Err, grdep.ErrExecNotAllowed) {
+		r.writeBlocked(arg, arg.Node.Err)
+		return nil
+	}
 	if errors.Is(arg.Node.Err, grdep.ErrUnmatched) {
 		return nil
 	}
@@ -140,6 +508,14 @@ func (r runner) processNode(ctx context.Context, arg PassArg) error {
 	return nil
 }
 
+// writeBlocked writes an error result of the matcher blocked by the sandbox,
+// so the dependencies that the matcher could find are not silently lost.
+func (r runner) writeBlocked(arg PassArg, err error) {
+	grdep.AddMetricCount("exec-not-allowed", 1)
+	arg.Err = err
+	r.write(arg.intoResult())
+}
+
 func (r runner) processNormalizedNode(_ context.Context, arg PassArg) error {
 	r.debug(func() { r.logger.Debug("process normalized node", "arg", jsonify(arg)) })
 
@@ -25,8 +25,10 @@ const skeletonYAML = `---
 # 1. Ignore directories and files according to 'ignore'.
 # 2. Determine the file's category according to 'category'.
 # 3. Normalize categories according to 'normalizer.category'.
-# 4. Find nodes (dependencies) according to 'node'.
-# 5. Normalize nodes according to 'normalizer.node'.
+# 4. Remove comments according to 'comment'.
+# 5. Join lines into logical lines according to 'continuation'.
+# 6. Find nodes (dependencies) according to 'node'.
+# 7. Normalize nodes according to 'normalizer.node'.
 #
 # The following can be written in matchers:
 #
@@ -43,6 +45,8 @@ const skeletonYAML = `---
 #     - r: "REGEXP"
 #       tmpl: "TEMPLATE"
 #
+# Variables of the file like ${project_root} (see 'marker') are also replaced.
+#
 # 'val' holds constants.
 # Pass the constants to the next.
 #
@@ -103,13 +107,63 @@ const skeletonYAML = `---
 #     - lua_file: "LUA_SCRIPT_FILE"
 #       lua_call: "LUA_ENTRYPOINT"
 #
+# 'lua_hooks' calls hooks in the lua script instead of 'lua_call'.
+# The hooks keep state between lines of a file, the script is evaluated again with new global variables for each file.
+# 'on_line' should return a string or nil, 'on_file_begin' and 'on_file_end' are optional.
+# 'ctx' has 'path', 'linum' and 'category'.
+#
+#   matcher:
+#     - lua: |
+#         local section
+#         function on_file_begin(path)
+#           section = nil
+#         end
+#         function on_line(line, ctx)
+#           local s = string.match(line, "^%[(.+)%]$")
+#           if s then
+#             section = s
+#           elseif section == "dependencies" then
+#             return line
+#           end
+#         end
+#         function on_file_end()
+#         end
+#       lua_hooks: true
+#
+# Lua scripts can load the 'grdep' module, which provides go regexp and other helpers.
+# 'require' also searches modules in the directories of the config file and 'lua_file'.
+#
+#   local grdep = require("grdep")
+#   grdep.match(pattern, s)          -- true if s matches the go regexp
+#   grdep.find(pattern, s)           -- submatches of the leftmost match, or nil
+#   grdep.find_all(pattern, s)       -- list of submatches
+#   grdep.replace(pattern, s, repl)  -- replace matches, repl can contain $1 or ${name}
+#   grdep.split(s, sep), grdep.trim(s), grdep.fields(s), grdep.has_prefix(s, p), grdep.has_suffix(s, p)
+#   grdep.path.base(p), dir(p), ext(p), join(...), clean(p), split(p), rel(base, p), is_abs(p), match(glob, p)
+#   grdep.json.decode(s), grdep.json.encode(v), grdep.yaml.decode(s), grdep.yaml.encode(v)
+#   grdep.semver.compare(a, b)       -- -1, 0 or 1
+#   grdep.log.debug(msg, key, value, ...), also info, warn and error
+#
 #
 # List of matchers for files and directories to ignore.
 ignore:
   - r: "ignore"
+# Ignore files that have any of the attributes in .gitattributes.
+# .gitattributes are read from the directory of the file up to the root of the git repository,
+# or up to the root of the walk outside git repositories.
+ignore_gitattributes:
+  - linguist-generated
+  - linguist-vendored
+# How to choose categories when multiple selectors match: all (default), first or priority.
+# 'first' selects categories of the first matched selector.
+# 'priority' selects categories of the matched selectors with the highest 'priority' (default 0).
+# The same categories after normalization are merged, 'agreed' in the result lists the selectors.
+category_mode: all
 # Determine file categories from filename or text.
 category:
   - name: if filename matches then the categories are bash and sh
+    # Files compressed by gzip or bzip2 are decompressed,
+    # 'filename' and 'basename' of start.sh.gz are without the suffix like start.sh.
     filename:
       # matchers can be wrtten here
       - r: "\\.sh$"
@@ -121,11 +175,62 @@ category:
       - r: "\\.(?P<ext>\\w+)$"
         tmpl: "$ext"
   - name: if file content matches then the category is bash
+    # 'head_only' reads only the first line like shebang.
+    # 'max_lines' and 'max_bytes' limit lines to read, default reads the whole file until a match.
+    head_only: true
+    priority: 10
     text:
       # matchers can be wrtten here
       - r: "#!/bin/bash"
       - val:
           - "bash"
+  - name: executables in bin are bash
+    # 'basename', 'dirname' and 'symlink' match the name of the file,
+    # the name of the parent directory and the target of the symlink.
+    # 'stat' is a condition on the metadata of the file, evaluated before the matchers.
+    # 'symlink' checks the file itself, the others check the target of the symlink.
+    stat:
+      executable: true
+      # symlink: false
+      # min_size: 1
+      # max_size: 1048576 # 0 for empty files
+      # modified_after: 720h # RFC3339 or a duration before now
+      # modified_before: "2006-01-02T15:04:05Z"
+    dirname:
+      - r: "^bin$"
+      - val:
+          - "bash"
+  - name: helm chart
+    # 'marker' finds the nearest ancestor directory that contains any of 'files'.
+    # 'matcher' receives the name of the found file, the category is the name if 'matcher' is omitted.
+    # The directory is set to the variable 'var' (default project_root) of the file,
+    # available in templates, lua (ctx.vars) and 'vars' in the result.
+    marker:
+      files:
+        - Chart.yaml
+      # var: project_root
+      matcher:
+        - val:
+            - "helm-chart"
+  - name: category from gitattributes
+    # 'gitattributes' matches the value of 'attr' in .gitattributes, "true" if it is set like 'linguist-vendored'.
+    # The category is the value if 'matcher' is omitted, e.g. 'grdep-category=bash' or 'linguist-language=Shell'.
+    gitattributes:
+      attr: grdep-category
+  - name: html by MIME type
+    # 'mime' matches the MIME type detected from the first 512 bytes of the content.
+    # Binary files are skipped unless --binary is passed.
+    mime:
+      - r: "^text/html"
+      - val:
+          - "html"
+# Declare that categories are kinds of other categories.
+# Node selectors for a category also apply to its descendants,
+# e.g. 'category: "^container$"' applies to dockerfile.
+hierarchy:
+  - category: dockerfile
+    parents:
+      - container
 # Find dependencies.
 node:
   - name: create bash node
@@ -163,6 +268,23 @@ node:
             return string.upper(string.gsub(src, "\"", ""))
           end
         lua_call: up
+  - name: apt-get packages
+    category: "dockerfile"
+    # 'mode: file' runs the first matcher 'r' (and 'tmpl') across the whole file with multiline flag.
+    # Other matchers receive each match.
+    mode: file
+    matcher:
+      - r: "apt-get install(?:\\s+(?:-\\S+|\\\\))*\\s+(?P<v>\\w+)"
+        tmpl: "$v"
+  - name: go imports
+    category: "go"
+    # 'scope' limits lines to blocks between 'begin' and 'end', exclusive.
+    scope:
+      begin: "^import \\($"
+      end: "^\\)$"
+    matcher:
+      - r: "\"(?P<v>[^\"]+)\""
+        tmpl: "$v"
 # Normalize categories and nodes.
 # If there is no match, the value remains as is.
 normalizer:
@@ -176,4 +298,34 @@ normalizer:
     - name: extract binary name
       matcher:
         - r: "/usr/bin/(?P<v>\\w+)"
-          tmpl: "$v"`
+          tmpl: "$v"
+# Join lines into logical lines of the categories before finding nodes.
+# The first matching rule is used.
+# The result has the first line number as 'linum' and the last as 'end_linum'.
+continuation:
+  - name: join lines ending with backslash
+    category: "^(bash|dockerfile)$"
+    suffix: "\\"
+  # - name: join lines until brackets are closed
+  #   category: "^python$"
+  #   brackets: true
+# Remove comments of the categories before joining lines.
+# The first matching rule is used.
+# 'language' is a builtin syntax: c, go, html, javascript, lua, python, shell, sql, yaml.
+# 'line' (prefixes of line comments), 'block' (delimiters of block comments),
+# 'quote' (delimiters of string literals), 'raw_quote' (delimiters of string literals without escapes)
+# and 'word_start' (line comments start only at the beginning of words like # in shell) extend the syntax.
+# 'action: drop' (default) removes comments and drops lines that consist of comments only.
+# 'action: tag' keeps lines and sets 'in_comment' to lines that consist of comments only.
+comment:
+  - name: shell comments
+    category: "^(bash|dockerfile)$"
+    language: shell
+  # - name: c comments
+  #   category: "^c$"
+  #   line: ["//"]
+  #   block:
+  #     - begin: "/*"
+  #       end: "*/"
+  #   quote: ["\""]
+  #   action: tag`
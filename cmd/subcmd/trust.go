@@ -0,0 +1,140 @@
+package subcmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/berquerant/grdep"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.PersistentFlags().String("allowlist", "", `File of trusted config hashes.
+Configs in it can run sh matchers and lua with os and io libraries.
+default: $XDG_CONFIG_HOME/grdep/allowlist`)
+	rootCmd.AddCommand(trustCmd)
+}
+
+var trustCmd = &cobra.Command{
+	Use:   "trust FILE_OR_TEXT [FILE_OR_TEXT]",
+	Short: "Add configs to allowlist",
+	Long: `Add configs to allowlist.
+The hashes of the configs are appended to the allowlist,
+then run can execute sh matchers and lua with os and io libraries in the configs.
+The hashes include the scripts of lua_file and the lua files in the directories searched by require,
+so changing the scripts or the modules requires trusting the configs again.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return errNoConfigFiles
+		}
+		path, err := getAllowlistPath(cmd)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		fp, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		defer fp.Close()
+
+		for i, config := range args {
+			c, err := grdep.ParseConfig(config)
+			if err != nil {
+				return fmt.Errorf("%w: config[%d] %s", err, i, config)
+			}
+			if _, err := fmt.Fprintf(fp, "%s %s\n", c.Hash(), strings.ReplaceAll(config, "\n", " ")); err != nil {
+				return err
+			}
+			fmt.Println(c.Hash())
+		}
+		return nil
+	},
+}
+
+func getAllowlistPath(cmd *cobra.Command) (string, error) {
+	if path, _ := cmd.Flags().GetString("allowlist"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "grdep", "allowlist"), nil
+}
+
+// readAllowlist reads trusted config hashes.
+// Each line starts with a hash, the rest of the line and lines starting with # are ignored.
+func readAllowlist(path string) (map[string]bool, error) {
+	result := map[string]bool{}
+	fp, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		result[strings.Fields(line)[0]] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+type trust struct {
+	allowExec bool
+	allowlist map[string]bool
+}
+
+func newTrust(cmd *cobra.Command) (*trust, error) {
+	allowExec, _ := cmd.Flags().GetBool("allow-exec")
+	if allowExec {
+		return &trust{
+			allowExec: true,
+		}, nil
+	}
+	path, err := getAllowlistPath(cmd)
+	if err != nil {
+		return nil, err
+	}
+	allowlist, err := readAllowlist(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: allowlist %s", err, path)
+	}
+	return &trust{
+		allowlist: allowlist,
+	}, nil
+}
+
+// apply sandboxes the config unless it is trusted.
+func (t trust) apply(c *grdep.Config, name string) {
+	if t.allowExec || t.allowlist[c.Hash()] {
+		return
+	}
+	for _, x := range c.Sandbox() {
+		switch x.Restriction {
+		case grdep.SandboxBlocked:
+			grdep.L().Warn("blocked matcher, pass --allow-exec or run trust to allow it",
+				"config", name, "hash", c.Hash(), "matcher", x.Location)
+		case grdep.SandboxRestricted:
+			grdep.L().Warn("restricted lua matcher, pass --allow-exec or run trust to allow os and io libraries",
+				"config", name, "hash", c.Hash(), "matcher", x.Location)
+		}
+	}
+}
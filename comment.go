@@ -0,0 +1,255 @@
+package grdep
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// CommentActionDrop removes comments and drops lines that consist of comments only.
+	CommentActionDrop = "drop"
+	// CommentActionTag keeps lines and sets InComment to lines that consist of comments only.
+	CommentActionTag = "tag"
+)
+
+// CommentBlock is a pair of block comment delimiters.
+type CommentBlock struct {
+	Begin string `yaml:"begin" json:"begin"`
+	End   string `yaml:"end" json:"end"`
+}
+
+// CommentSyntax is a comment syntax of a language.
+type CommentSyntax struct {
+	// Line holds the prefixes of line comments.
+	Line []string
+	// Block holds the delimiters of block comments.
+	Block []CommentBlock
+	// Quote holds the delimiters of string literals, comments in them are ignored.
+	// Backslash escapes the next character in them.
+	Quote []string
+	// RawQuote holds the delimiters of string literals without escapes, e.g. ' in shell.
+	RawQuote []string
+	// WordStart means line comments start only at the beginning of a word,
+	// after the start of the line or whitespace, e.g. # in shell.
+	WordStart bool
+}
+
+func (s CommentSyntax) add(other CommentSyntax) CommentSyntax {
+	return CommentSyntax{
+		Line:      append(append([]string{}, s.Line...), other.Line...),
+		Block:     append(append([]CommentBlock{}, s.Block...), other.Block...),
+		Quote:     append(append([]string{}, s.Quote...), other.Quote...),
+		RawQuote:  append(append([]string{}, s.RawQuote...), other.RawQuote...),
+		WordStart: s.WordStart || other.WordStart,
+	}
+}
+
+var commentLanguages = map[string]CommentSyntax{
+	"shell": {
+		Line:      []string{"#"},
+		Quote:     []string{`"`},
+		RawQuote:  []string{`'`},
+		WordStart: true,
+	},
+	"python": {
+		Line:  []string{"#"},
+		Quote: []string{`"`, `'`},
+	},
+	"yaml": {
+		Line:      []string{"#"},
+		Quote:     []string{`"`},
+		RawQuote:  []string{`'`},
+		WordStart: true,
+	},
+	"c": {
+		Line:  []string{"//"},
+		Block: []CommentBlock{{Begin: "/*", End: "*/"}},
+		Quote: []string{`"`, `'`},
+	},
+	"go": {
+		Line:     []string{"//"},
+		Block:    []CommentBlock{{Begin: "/*", End: "*/"}},
+		Quote:    []string{`"`, `'`},
+		RawQuote: []string{"`"},
+	},
+	"javascript": {
+		Line:  []string{"//"},
+		Block: []CommentBlock{{Begin: "/*", End: "*/"}},
+		Quote: []string{`"`, "`", `'`},
+	},
+	"lua": {
+		Line:  []string{"--"},
+		Block: []CommentBlock{{Begin: "--[[", End: "]]"}},
+		Quote: []string{`"`, `'`},
+	},
+	"sql": {
+		Line:     []string{"--"},
+		Block:    []CommentBlock{{Begin: "/*", End: "*/"}},
+		RawQuote: []string{`'`},
+	},
+	"html": {
+		Block: []CommentBlock{{Begin: "<!--", End: "-->"}},
+		Quote: []string{`"`, `'`},
+	},
+}
+
+// CommentLanguages returns the names of the builtin comment syntaxes.
+func CommentLanguages() []string {
+	names := make([]string, 0, len(commentLanguages))
+	for name := range commentLanguages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type Comments []Comment
+
+// NewCommentFilter returns a filter of the first comment that matches the category,
+// nil if there is no match.
+func (c Comments) NewCommentFilter(category string) *CommentFilter {
+	for _, x := range c {
+		if x.Category.Unwrap().MatchString(category) {
+			return NewCommentFilter(x.Syntax(), x.Action)
+		}
+	}
+	return nil
+}
+
+func NewCommentFilter(syntax CommentSyntax, action string) *CommentFilter {
+	if action == "" {
+		action = CommentActionDrop
+	}
+	// Try longer delimiters first, e.g. --[[ before --.
+	syntax = syntax.add(CommentSyntax{}) // copy before sorting
+	sort.SliceStable(syntax.Line, func(i, j int) bool { return len(syntax.Line[i]) > len(syntax.Line[j]) })
+	sort.SliceStable(syntax.Block, func(i, j int) bool { return len(syntax.Block[i].Begin) > len(syntax.Block[j].Begin) })
+	return &CommentFilter{
+		syntax: syntax,
+		action: action,
+	}
+}
+
+// CommentFilter removes or tags comments of lines of a file.
+// Block comments can span lines, string literals cannot.
+type CommentFilter struct {
+	syntax CommentSyntax
+	action string
+
+	blockEnd string // not empty if in a block comment
+}
+
+// Filter returns the line and true if the line should be passed to the next.
+func (f *CommentFilter) Filter(line Line) (Line, bool) {
+	code, hasComment := f.strip(line.Content)
+	commentOnly := hasComment && strings.TrimSpace(code) == ""
+
+	switch f.action {
+	case CommentActionTag:
+		line.InComment = commentOnly
+		return line, true
+	default:
+		if commentOnly {
+			return Line{}, false
+		}
+		if hasComment {
+			line.Content = strings.TrimRight(code, " \t")
+		}
+		return line, true
+	}
+}
+
+// strip returns the content without comments and true if the content has comments.
+func (f *CommentFilter) strip(content string) (string, bool) {
+	var (
+		b          strings.Builder
+		hasComment = f.blockEnd != ""
+		quote      string
+		raw        bool // quote has no escapes
+	)
+
+	for i := 0; i < len(content); {
+		rest := content[i:]
+
+		if f.blockEnd != "" {
+			hasComment = true
+			j := strings.Index(rest, f.blockEnd)
+			if j < 0 {
+				return b.String(), true
+			}
+			i += j + len(f.blockEnd)
+			f.blockEnd = ""
+			b.WriteByte(' ')
+			continue
+		}
+
+		if quote != "" {
+			switch {
+			case !raw && rest[0] == '\\' && len(rest) > 1:
+				b.WriteString(rest[:2])
+				i += 2
+			case strings.HasPrefix(rest, quote):
+				b.WriteString(quote)
+				i += len(quote)
+				quote = ""
+			default:
+				b.WriteByte(rest[0])
+				i++
+			}
+			continue
+		}
+
+		if block, ok := f.blockBegin(rest); ok {
+			hasComment = true
+			f.blockEnd = block.End
+			i += len(block.Begin)
+			continue
+		}
+		if f.lineBegin(rest, i == 0 || content[i-1] == ' ' || content[i-1] == '\t') {
+			return b.String(), true
+		}
+		if q, ok := hasAnyPrefix(rest, f.syntax.Quote); ok {
+			quote, raw = q, false
+			b.WriteString(q)
+			i += len(q)
+			continue
+		}
+		if q, ok := hasAnyPrefix(rest, f.syntax.RawQuote); ok {
+			quote, raw = q, true
+			b.WriteString(q)
+			i += len(q)
+			continue
+		}
+		b.WriteByte(rest[0])
+		i++
+	}
+
+	return b.String(), hasComment
+}
+
+func (f *CommentFilter) blockBegin(s string) (CommentBlock, bool) {
+	for _, x := range f.syntax.Block {
+		if strings.HasPrefix(s, x.Begin) {
+			return x, true
+		}
+	}
+	return CommentBlock{}, false
+}
+
+// lineBegin returns true if s starts with a line comment, wordStart is true if s is at the beginning of a word.
+func (f *CommentFilter) lineBegin(s string, wordStart bool) bool {
+	if f.syntax.WordStart && !wordStart {
+		return false
+	}
+	_, ok := hasAnyPrefix(s, f.syntax.Line)
+	return ok
+}
+
+func hasAnyPrefix(s string, prefixes []string) (string, bool) {
+	for _, x := range prefixes {
+		if strings.HasPrefix(s, x) {
+			return x, true
+		}
+	}
+	return "", false
+}
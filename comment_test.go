@@ -0,0 +1,185 @@
+package grdep_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/grdep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommentFilter(t *testing.T) {
+	var (
+		shell = grdep.CommentSyntax{
+			Line:      []string{"#"},
+			Quote:     []string{`"`},
+			RawQuote:  []string{`'`},
+			WordStart: true,
+		}
+		golang = grdep.CommentSyntax{
+			Line:     []string{"//"},
+			Block:    []grdep.CommentBlock{{Begin: "/*", End: "*/"}},
+			Quote:    []string{`"`, `'`},
+			RawQuote: []string{"`"},
+		}
+		c = grdep.CommentSyntax{
+			Line:  []string{"//"},
+			Block: []grdep.CommentBlock{{Begin: "/*", End: "*/"}},
+			Quote: []string{`"`},
+		}
+	)
+
+	for _, tc := range []struct {
+		name   string
+		syntax grdep.CommentSyntax
+		action string
+		lines  []string
+		want   []grdep.Line
+	}{
+		{
+			name:   "no comments",
+			syntax: shell,
+			lines:  []string{"FROM debian", ""},
+			want: []grdep.Line{
+				{Linum: 1, Content: "FROM debian"},
+				{Linum: 2, Content: ""},
+			},
+		},
+		{
+			name:   "drop line comments",
+			syntax: shell,
+			lines: []string{
+				"# FROM old-image",
+				"  # indented",
+				"FROM debian # base",
+			},
+			want: []grdep.Line{
+				{Linum: 3, Content: "FROM debian"},
+			},
+		},
+		{
+			name:   "quoted",
+			syntax: shell,
+			lines: []string{
+				`echo "# not a comment" # comment`,
+				`echo 'it''s' "\"#"`,
+			},
+			want: []grdep.Line{
+				{Linum: 1, Content: `echo "# not a comment"`},
+				{Linum: 2, Content: `echo 'it''s' "\"#"`},
+			},
+		},
+		{
+			name:   "word start",
+			syntax: shell,
+			lines: []string{
+				`echo ${#arr[@]}; curl http://x/#a`,
+				"a=1\t# tab",
+				"#!/bin/bash",
+			},
+			want: []grdep.Line{
+				{Linum: 1, Content: `echo ${#arr[@]}; curl http://x/#a`},
+				{Linum: 2, Content: "a=1"},
+			},
+		},
+		{
+			name:   "raw quotes",
+			syntax: shell,
+			lines: []string{
+				`echo 'a\' # comment`,
+				`echo "a\" # not a comment"`,
+			},
+			want: []grdep.Line{
+				{Linum: 1, Content: `echo 'a\'`},
+				{Linum: 2, Content: `echo "a\" # not a comment"`},
+			},
+		},
+		{
+			name:   "go raw strings",
+			syntax: golang,
+			lines: []string{
+				"p := `C:\\` // comment",
+				`s := "\"//" // comment`,
+			},
+			want: []grdep.Line{
+				{Linum: 1, Content: "p := `C:\\`"},
+				{Linum: 2, Content: `s := "\"//"`},
+			},
+		},
+		{
+			name:   "block comments",
+			syntax: c,
+			lines: []string{
+				`a /* b */ c`,
+				`/*`,
+				`import "x"`,
+				``,
+				`*/ d // e`,
+				`"/*" f`,
+			},
+			want: []grdep.Line{
+				{Linum: 1, Content: "a   c"},
+				{Linum: 5, Content: "  d"},
+				{Linum: 6, Content: `"/*" f`},
+			},
+		},
+		{
+			name:   "tag",
+			syntax: c,
+			action: grdep.CommentActionTag,
+			lines: []string{
+				`// import "x"`,
+				`import "y" // z`,
+				`/* a`,
+				`b */`,
+			},
+			want: []grdep.Line{
+				{Linum: 1, Content: `// import "x"`, InComment: true},
+				{Linum: 2, Content: `import "y" // z`},
+				{Linum: 3, Content: `/* a`, InComment: true},
+				{Linum: 4, Content: `b */`, InComment: true},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := grdep.NewCommentFilter(tc.syntax, tc.action)
+			got := []grdep.Line{}
+			for i, x := range tc.lines {
+				if line, ok := f.Filter(grdep.Line{
+					Linum:   i + 1,
+					Content: x,
+				}); ok {
+					got = append(got, line)
+				}
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestComments(t *testing.T) {
+	comments := grdep.Comments{
+		{
+			Category: grdep.NewRegexp(`^lua$`),
+			Language: "lua",
+		},
+	}
+	assert.Nil(t, comments.NewCommentFilter("go"))
+
+	f := comments.NewCommentFilter("lua")
+	if !assert.NotNil(t, f) {
+		return
+	}
+	for _, tc := range []struct {
+		content string
+		want    string
+		ok      bool
+	}{
+		{content: `--[[ require("a")`},
+		{content: `]] require("b") -- c`, want: `  require("b")`, ok: true},
+		{content: `-- require("d")`},
+	} {
+		got, ok := f.Filter(grdep.Line{Content: tc.content})
+		assert.Equal(t, tc.ok, ok, tc.content)
+		assert.Equal(t, tc.want, got.Content, tc.content)
+	}
+}
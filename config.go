@@ -2,13 +2,19 @@ package grdep
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -32,11 +38,28 @@ func parseConfigFile(configFile string) (*Config, error) {
 	}
 	defer fp.Close()
 
-	return NewConfigParser().Parse(fp)
+	c, err := NewConfigParser().Parse(fp)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(filepath.Dir(configFile))
+	if err != nil {
+		return nil, err
+	}
+	c.WalkMatchers(func(_ string, m *Matcher) {
+		m.dir = dir
+	})
+	c.hash = c.hashLuaModules()
+	return c, nil
 }
 
 func parseConfigText(configText string) (*Config, error) {
-	return NewConfigParser().Parse(bytes.NewBufferString(configText))
+	c, err := NewConfigParser().Parse(bytes.NewBufferString(configText))
+	if err != nil {
+		return nil, err
+	}
+	c.hash = c.hashLuaModules()
+	return c, nil
 }
 
 func NewConfigParser() *ConfigParser {
@@ -57,6 +80,7 @@ func (p ConfigParser) Parse(r io.Reader) (*Config, error) {
 	if err := c.Validate(); err != nil {
 		return nil, err
 	}
+	c.hash = c.loadLuaFiles(b)
 	return c, nil
 }
 
@@ -99,26 +123,184 @@ var (
 	_ Validatable = &CSelector{}
 	_ Validatable = &NSelector{}
 	_ Validatable = &Normalizers{}
+	_ Validatable = &Continuation{}
+	_ Validatable = &Comment{}
+	_ Validatable = &CategoryHierarchy{}
 )
 
 type Config struct {
 	// Ignore files with matching paths.
 	Ignores []*Matcher `yaml:"ignore,omitempty" json:"ignore,omitempty"`
+	// Ignore files with any of the attributes in .gitattributes, e.g. linguist-generated.
+	IgnoreGitAttributes []string `yaml:"ignore_gitattributes,omitempty" json:"ignore_gitattributes,omitempty"`
 	// Select file category.
 	Categories []CSelector `yaml:"category" json:"category"`
+	// CategoryMode is all (default), first or priority.
+	CategoryMode string `yaml:"category_mode,omitempty" json:"category_mode,omitempty"`
+	// Declare is-a relationships between categories.
+	Hierarchy CategoryHierarchy `yaml:"hierarchy,omitempty" json:"hierarchy,omitempty"`
 	// Find nodes corresponding to categories.
 	Nodes []NSelector `yaml:"node" json:"node"`
 	// Normalize categories and nodes.
 	Normalizers Normalizers `yaml:"normalizer,omitempty" json:"normalizer,omitempty"`
+	// Join lines into logical lines before finding nodes.
+	Continuations Continuations `yaml:"continuation,omitempty" json:"continuation,omitempty"`
+	// Remove or tag comments before joining lines.
+	Comments Comments `yaml:"comment,omitempty" json:"comment,omitempty"`
+
+	// hash is the sha256 of the source of the config.
+	hash string
+}
+
+// Hash returns the sha256 of the source of the config, empty if the config is not parsed.
+// It also covers the scripts of lua_file and the modules that lua matchers can require.
+func (c Config) Hash() string {
+	return c.hash
+}
+
+// loadLuaFiles reads the scripts of lua_file and returns the sha256 of the source of the config and the scripts,
+// so a change of the scripts also changes the hash. The scripts read here are the ones to run.
+func (c Config) loadLuaFiles(src []byte) string {
+	h := sha256.New()
+	_, _ = h.Write(src)
+	c.WalkMatchers(func(_ string, m *Matcher) {
+		if m.LuaFile == "" {
+			return
+		}
+		m.luaFileContent, m.luaFileErr = os.ReadFile(m.LuaFile)
+		_, _ = fmt.Fprintf(h, "\x00lua_file\x00%s\x00%d\x00", m.LuaFile, len(m.luaFileContent))
+		if m.luaFileErr != nil {
+			_, _ = fmt.Fprintf(h, "error\x00%s", m.luaFileErr)
+			return
+		}
+		_, _ = h.Write(m.luaFileContent)
+	})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashLuaModules returns the hash with the lua files in the directories where require of lua matchers searches modules,
+// so a change of the modules also changes the hash. The hash is unchanged if no lua matchers exist.
+func (c Config) hashLuaModules() string {
+	var (
+		dirs = []string{}
+		seen = map[string]bool{}
+	)
+	c.WalkMatchers(func(_ string, m *Matcher) {
+		if m.Lua == "" && m.LuaFile == "" {
+			return
+		}
+		for _, dir := range m.luaDirs() {
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	})
+	if len(dirs) == 0 {
+		return c.hash
+	}
+
+	h := sha256.New()
+	_, _ = h.Write([]byte(c.hash))
+	for _, dir := range dirs {
+		_, _ = fmt.Fprintf(h, "\x00lua_module_dir\x00%s", dir)
+		_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				_, _ = fmt.Fprintf(h, "\x00error\x00%s", err)
+				return nil
+			}
+			// require cannot resolve names starting with a dot
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() || filepath.Ext(path) != ".lua" {
+				return nil
+			}
+			b, err := os.ReadFile(path)
+			rel, _ := filepath.Rel(dir, path)
+			_, _ = fmt.Fprintf(h, "\x00lua_module\x00%s\x00%d\x00", rel, len(b))
+			if err != nil {
+				_, _ = fmt.Fprintf(h, "error\x00%s", err)
+				return nil
+			}
+			_, _ = h.Write(b)
+			return nil
+		})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+const (
+	// SandboxBlocked means the matcher is not executed.
+	SandboxBlocked = "blocked"
+	// SandboxRestricted means the matcher is executed with restrictions.
+	SandboxRestricted = "restricted"
+)
+
+// SandboxedMatcher is a matcher affected by the sandbox.
+type SandboxedMatcher struct {
+	Location string
+	// Restriction is blocked for sh matchers, restricted for lua matchers.
+	Restriction string
+}
+
+// Sandbox disables the execution of scripts that can affect the system.
+// sh matchers are blocked and lua matchers are loaded without os and io libraries,
+// dofile and loadfile, and require searches only the directories of the config and the script.
+// Returns the matchers affected by the sandbox.
+func (c Config) Sandbox() []SandboxedMatcher {
+	sandboxed := []SandboxedMatcher{}
+	c.WalkMatchers(func(location string, m *Matcher) {
+		m.sandboxed = true
+		switch {
+		case m.Shell != "":
+			sandboxed = append(sandboxed, SandboxedMatcher{
+				Location:    location,
+				Restriction: SandboxBlocked,
+			})
+		case m.Lua != "" || m.LuaFile != "":
+			sandboxed = append(sandboxed, SandboxedMatcher{
+				Location:    location,
+				Restriction: SandboxRestricted,
+			})
+		}
+	})
+	return sandboxed
+}
+
+// LimitLuaPool keeps at most size idle states of each lua matcher, e.g. the number of workers.
+func (c Config) LimitLuaPool(size int) {
+	c.WalkMatchers(func(_ string, m *Matcher) {
+		m.luaPoolSize = size
+	})
 }
 
 func (c Config) Validate() error {
+	switch c.CategoryMode {
+	case "", CategoryModeAll, CategoryModeFirst, CategoryModePriority:
+	default:
+		return fmt.Errorf("%w: unknown category_mode %s", ErrInvalidConfig, c.CategoryMode)
+	}
+
+	for i, x := range c.IgnoreGitAttributes {
+		if x == "" {
+			return fmt.Errorf("%w: ignore_gitattributes[%d] is empty", ErrInvalidConfig, i)
+		}
+	}
+
 	for i, x := range c.Categories {
 		if err := x.Validate(); err != nil {
 			return fmt.Errorf("%w: category[%d]", err, i)
 		}
 	}
 
+	if err := c.Hierarchy.Validate(); err != nil {
+		return err
+	}
+
 	for i, x := range c.Nodes {
 		if err := x.Validate(); err != nil {
 			return fmt.Errorf("%w: node[%d]", err, i)
@@ -129,18 +311,75 @@ func (c Config) Validate() error {
 		return err
 	}
 
+	for i, x := range c.Continuations {
+		if err := x.Validate(); err != nil {
+			return fmt.Errorf("%w: continuation[%d]", err, i)
+		}
+	}
+
+	for i, x := range c.Comments {
+		if err := x.Validate(); err != nil {
+			return fmt.Errorf("%w: comment[%d]", err, i)
+		}
+	}
+
 	return nil
 }
 
+// Add concatenates configs.
+// CategoryMode of other takes precedence if it is set.
 func (c Config) Add(other Config) Config {
+	categoryMode := c.CategoryMode
+	if other.CategoryMode != "" {
+		categoryMode = other.CategoryMode
+	}
 	return Config{
-		Ignores:    append(c.Ignores, other.Ignores...),
-		Categories: append(c.Categories, other.Categories...),
-		Nodes:      append(c.Nodes, other.Nodes...),
+		Ignores:             append(c.Ignores, other.Ignores...),
+		IgnoreGitAttributes: append(c.IgnoreGitAttributes, other.IgnoreGitAttributes...),
+		Categories:          append(c.Categories, other.Categories...),
+		CategoryMode:        categoryMode,
+		Hierarchy:           append(c.Hierarchy, other.Hierarchy...),
+		Nodes:               append(c.Nodes, other.Nodes...),
 		Normalizers: Normalizers{
 			Categories: append(c.Normalizers.Categories, other.Normalizers.Categories...),
 			Nodes:      append(c.Normalizers.Nodes, other.Normalizers.Nodes...),
 		},
+		Continuations: append(c.Continuations, other.Continuations...),
+		Comments:      append(c.Comments, other.Comments...),
+	}
+}
+
+// WalkMatchers calls f for each matcher with its location in the config.
+func (c Config) WalkMatchers(f func(location string, m *Matcher)) {
+	walk := func(prefix string, matchers []*Matcher) {
+		for i, m := range matchers {
+			f(fmt.Sprintf("%s[%d]", prefix, i), m)
+		}
+	}
+
+	walk("ignore", c.Ignores)
+	for _, x := range c.Categories {
+		walk(fmt.Sprintf("category(%s) filename", x.Name), x.Filename)
+		walk(fmt.Sprintf("category(%s) text", x.Name), x.Text)
+		walk(fmt.Sprintf("category(%s) basename", x.Name), x.Basename)
+		walk(fmt.Sprintf("category(%s) dirname", x.Name), x.Dirname)
+		walk(fmt.Sprintf("category(%s) symlink", x.Name), x.Symlink)
+		walk(fmt.Sprintf("category(%s) mime", x.Name), x.Mime)
+		if x.Marker != nil {
+			walk(fmt.Sprintf("category(%s) marker", x.Name), x.Marker.Matcher)
+		}
+		if x.GitAttributes != nil {
+			walk(fmt.Sprintf("category(%s) gitattributes", x.Name), x.GitAttributes.Matcher)
+		}
+	}
+	for _, x := range c.Nodes {
+		walk(fmt.Sprintf("node(%s) matcher", x.Name), x.Matcher)
+	}
+	for _, x := range c.Normalizers.Categories {
+		walk(fmt.Sprintf("category normalizer(%s) matcher", x.Name), x.Matcher)
+	}
+	for _, x := range c.Normalizers.Nodes {
+		walk(fmt.Sprintf("node normalizer(%s) matcher", x.Name), x.Matcher)
 	}
 }
 
@@ -158,10 +397,20 @@ type Matcher struct {
 	Lua           string   `yaml:"lua,omitempty" json:"lua,omitempty"`
 	LuaFile       string   `yaml:"lua_file,omitempty" json:"lua_file,omitempty"`
 	LuaEntryPoint string   `yaml:"lua_call,omitempty" json:"lua_call,omitempty"`
+	LuaHooks      bool     `yaml:"lua_hooks,omitempty" json:"lua_hooks,omitempty"`
 
 	shellScript *ShellScript `yaml:"-" json:"-"`
 	luaScript   *LuaScript   `yaml:"-" json:"-"`
 	mux         sync.Mutex   `yaml:"-" json:"-"`
+	// dir is the directory of the config file, require in lua searches modules in it.
+	dir string `yaml:"-" json:"-"`
+	// sandboxed disables sh and restricts lua.
+	sandboxed bool `yaml:"-" json:"-"`
+	// luaPoolSize is the max number of idle lua states.
+	luaPoolSize int `yaml:"-" json:"-"`
+	// luaFileContent is the script of lua_file read when the config is parsed.
+	luaFileContent []byte `yaml:"-" json:"-"`
+	luaFileErr     error  `yaml:"-" json:"-"`
 }
 
 func (m *Matcher) countSettings() int {
@@ -193,6 +442,9 @@ func (m *Matcher) countSettings() int {
 	if m.LuaEntryPoint != "" {
 		c++
 	}
+	if m.LuaHooks {
+		c++
+	}
 	return c
 }
 
@@ -206,10 +458,12 @@ func (m *Matcher) Validate() error {
 			return fmt.Errorf("%w: tmpl requires r", ErrInvalidConfig)
 		case m.LuaEntryPoint != "":
 			return fmt.Errorf("%w: lua_call requires lua or lua_file", ErrInvalidConfig)
+		case m.LuaHooks:
+			return fmt.Errorf("%w: lua_hooks requires lua or lua_file", ErrInvalidConfig)
 		case m.Lua != "":
-			return fmt.Errorf("%w: lua requires lua_call", ErrInvalidConfig)
+			return fmt.Errorf("%w: lua requires lua_call or lua_hooks", ErrInvalidConfig)
 		case m.LuaFile != "":
-			return fmt.Errorf("%w: lua_file requires lua_call", ErrInvalidConfig)
+			return fmt.Errorf("%w: lua_file requires lua_call or lua_hooks", ErrInvalidConfig)
 		default:
 			return nil
 		}
@@ -217,7 +471,7 @@ func (m *Matcher) Validate() error {
 		switch {
 		case m.Regex != nil && m.Template != "":
 			return nil
-		case m.LuaEntryPoint != "":
+		case m.LuaEntryPoint != "", m.LuaHooks:
 			if m.Lua != "" || m.LuaFile != "" {
 				return nil
 			}
@@ -225,7 +479,7 @@ func (m *Matcher) Validate() error {
 	}
 
 	return fmt.Errorf(
-		"%w: only (r, tmpl), (lua, lua_call), (lua_file, lua_call) can be specified at the same time",
+		"%w: only (r, tmpl), (lua, lua_call), (lua_file, lua_call), (lua, lua_hooks), (lua_file, lua_hooks) can be specified at the same time",
 		ErrInvalidConfig,
 	)
 }
@@ -269,35 +523,240 @@ type CSelector struct {
 	Name     string     `yaml:"name,omitempty" json:"name,omitempty"`
 	Filename []*Matcher `yaml:"filename,omitempty" json:"filename,omitempty"`
 	Text     []*Matcher `yaml:"text,omitempty" json:"text,omitempty"`
+	// Basename matches the name of the file.
+	Basename []*Matcher `yaml:"basename,omitempty" json:"basename,omitempty"`
+	// Dirname matches the name of the parent directory.
+	Dirname []*Matcher `yaml:"dirname,omitempty" json:"dirname,omitempty"`
+	// Symlink matches the target of the symlink.
+	Symlink []*Matcher `yaml:"symlink,omitempty" json:"symlink,omitempty"`
+	// Mime matches the MIME type detected from the content like text/plain; charset=utf-8.
+	Mime []*Matcher `yaml:"mime,omitempty" json:"mime,omitempty"`
+	// Marker finds marker files in ancestor directories.
+	Marker *MarkerSelector `yaml:"marker,omitempty" json:"marker,omitempty"`
+	// GitAttributes matches the value of an attribute in .gitattributes.
+	GitAttributes *GitAttributesSelector `yaml:"gitattributes,omitempty" json:"gitattributes,omitempty"`
+	// Stat is a condition on the metadata of the file before the matchers.
+	Stat *StatSelector `yaml:"stat,omitempty" json:"stat,omitempty"`
+	// MaxLines limits the number of lines to read for text, 0 means no limit.
+	MaxLines int `yaml:"max_lines,omitempty" json:"max_lines,omitempty"`
+	// MaxBytes limits the number of bytes to read for text, 0 means no limit.
+	MaxBytes int `yaml:"max_bytes,omitempty" json:"max_bytes,omitempty"`
+	// HeadOnly reads only the first line for text, e.g. shebang.
+	HeadOnly bool `yaml:"head_only,omitempty" json:"head_only,omitempty"`
+	// Priority is used when category_mode is priority, higher is preferred.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+}
+
+// ReadLimit returns the limits of text.
+func (s CSelector) ReadLimit() (maxLines, maxBytes int) {
+	if s.HeadOnly {
+		return 1, s.MaxBytes
+	}
+	return s.MaxLines, s.MaxBytes
 }
 
 func (s CSelector) Validate() error {
-	if !XOR(len(s.Filename) > 0, len(s.Text) > 0) {
-		return fmt.Errorf("%w: category(%s) should have only either filename or text", ErrInvalidConfig, s.Name)
+	var sources int
+	for _, x := range [][]*Matcher{s.Filename, s.Text, s.Basename, s.Dirname, s.Symlink, s.Mime} {
+		if len(x) > 0 {
+			sources++
+		}
+	}
+	if s.Marker != nil {
+		sources++
+	}
+	if s.GitAttributes != nil {
+		sources++
+	}
+	if sources != 1 {
+		return fmt.Errorf("%w: category(%s) should have only one of filename, text, basename, dirname, symlink, mime, marker or gitattributes", ErrInvalidConfig, s.Name)
+	}
+	if s.GitAttributes != nil {
+		if err := s.GitAttributes.Validate(); err != nil {
+			return fmt.Errorf("%w: category(%s) gitattributes", err, s.Name)
+		}
+	}
+	if s.Marker != nil {
+		if err := s.Marker.Validate(); err != nil {
+			return fmt.Errorf("%w: category(%s) marker", err, s.Name)
+		}
+	}
+	if s.MaxLines < 0 || s.MaxBytes < 0 {
+		return fmt.Errorf("%w: category(%s) max_lines and max_bytes should not be negative", ErrInvalidConfig, s.Name)
+	}
+	if s.HeadOnly && s.MaxLines > 0 {
+		return fmt.Errorf("%w: category(%s) head_only and max_lines cannot be specified at the same time", ErrInvalidConfig, s.Name)
+	}
+	if len(s.Text) == 0 && (s.HeadOnly || s.MaxLines > 0 || s.MaxBytes > 0) {
+		return fmt.Errorf("%w: category(%s) head_only, max_lines and max_bytes require text", ErrInvalidConfig, s.Name)
+	}
+	if s.Stat != nil {
+		if _, err := s.Stat.FileStat(time.Now()); err != nil {
+			return fmt.Errorf("%w: category(%s) stat", err, s.Name)
+		}
 	}
 
-	for i, x := range s.Filename {
-		if err := x.Validate(); err != nil {
-			return fmt.Errorf("%w: category(%s) filename[%d]", err, s.Name, i)
+	for _, x := range []struct {
+		name     string
+		matchers []*Matcher
+	}{
+		{"filename", s.Filename},
+		{"text", s.Text},
+		{"basename", s.Basename},
+		{"dirname", s.Dirname},
+		{"symlink", s.Symlink},
+		{"mime", s.Mime},
+	} {
+		for i, m := range x.matchers {
+			if err := m.Validate(); err != nil {
+				return fmt.Errorf("%w: category(%s) %s[%d]", err, s.Name, x.name, i)
+			}
 		}
 	}
 
-	for i, x := range s.Text {
+	return nil
+}
+
+// MarkerSelector finds the nearest ancestor directory that contains any of the files.
+type MarkerSelector struct {
+	// Files are the names of the marker files, e.g. go.mod.
+	Files []string `yaml:"files" json:"files"`
+	// Var is the name of the variable to set the directory, default is project_root.
+	Var string `yaml:"var,omitempty" json:"var,omitempty"`
+	// Matcher receives the name of the found file.
+	// The category is the name as is if it is empty.
+	Matcher []*Matcher `yaml:"matcher,omitempty" json:"matcher,omitempty"`
+}
+
+func (s MarkerSelector) Validate() error {
+	if len(s.Files) == 0 {
+		return fmt.Errorf("%w: marker requires files", ErrInvalidConfig)
+	}
+	for _, x := range s.Files {
+		if x == "" || strings.ContainsRune(x, filepath.Separator) {
+			return fmt.Errorf("%w: marker file %q should be a name", ErrInvalidConfig, x)
+		}
+	}
+	for i, x := range s.Matcher {
 		if err := x.Validate(); err != nil {
-			return fmt.Errorf("%w: category(%s) text[%d]", err, s.Name, i)
+			return fmt.Errorf("%w: matcher[%d]", err, i)
 		}
 	}
+	return nil
+}
+
+// GitAttributesSelector matches the value of the attribute in .gitattributes.
+type GitAttributesSelector struct {
+	// Attr is the name of the attribute, e.g. linguist-language.
+	Attr string `yaml:"attr" json:"attr"`
+	// Matcher receives the value of the attribute, "true" if it is set.
+	// The category is the value as is if it is empty.
+	Matcher []*Matcher `yaml:"matcher,omitempty" json:"matcher,omitempty"`
+}
 
+func (s GitAttributesSelector) Validate() error {
+	if s.Attr == "" {
+		return fmt.Errorf("%w: gitattributes requires attr", ErrInvalidConfig)
+	}
+	for i, x := range s.Matcher {
+		if err := x.Validate(); err != nil {
+			return fmt.Errorf("%w: matcher[%d]", err, i)
+		}
+	}
 	return nil
 }
 
+// StatSelector is a condition on the metadata of a file.
+// Symlink checks the file itself, the others check the target of the symlink.
+type StatSelector struct {
+	// Executable requires any of the executable bits to be set or not.
+	Executable *bool `yaml:"executable,omitempty" json:"executable,omitempty"`
+	// Symlink requires the file to be a symlink or not.
+	Symlink *bool `yaml:"symlink,omitempty" json:"symlink,omitempty"`
+	// MinSize and MaxSize limit the size in bytes, inclusive, e.g. max_size: 0 for empty files.
+	MinSize *int64 `yaml:"min_size,omitempty" json:"min_size,omitempty"`
+	MaxSize *int64 `yaml:"max_size,omitempty" json:"max_size,omitempty"`
+	// ModifiedAfter and ModifiedBefore limit the modification time.
+	// RFC3339 like 2024-01-02T15:04:05Z or a duration before now like 720h.
+	ModifiedAfter  string `yaml:"modified_after,omitempty" json:"modified_after,omitempty"`
+	ModifiedBefore string `yaml:"modified_before,omitempty" json:"modified_before,omitempty"`
+}
+
+// FileStat returns the condition, times are relative to now.
+func (s StatSelector) FileStat(now time.Time) (FileStat, error) {
+	if (s.MinSize != nil && *s.MinSize < 0) || (s.MaxSize != nil && *s.MaxSize < 0) {
+		return FileStat{}, fmt.Errorf("%w: min_size and max_size should not be negative", ErrInvalidConfig)
+	}
+	after, err := parseTimeBound(s.ModifiedAfter, now)
+	if err != nil {
+		return FileStat{}, fmt.Errorf("%w: modified_after", err)
+	}
+	before, err := parseTimeBound(s.ModifiedBefore, now)
+	if err != nil {
+		return FileStat{}, fmt.Errorf("%w: modified_before", err)
+	}
+	return FileStat{
+		Executable:     s.Executable,
+		Symlink:        s.Symlink,
+		MinSize:        s.MinSize,
+		MaxSize:        s.MaxSize,
+		ModifiedAfter:  after,
+		ModifiedBefore: before,
+	}, nil
+}
+
+func parseTimeBound(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s is neither RFC3339 nor duration", ErrInvalidConfig, s)
+	}
+	return now.Add(-d), nil
+}
+
 type NSelector struct {
 	Name     string     `yaml:"name,omitempty" json:"name,omitempty"`
 	Category Regexp     `yaml:"category" json:"category"`
 	Matcher  []*Matcher `yaml:"matcher" json:"matcher"`
+	// Scope limits lines to find nodes to blocks.
+	Scope *BlockScope `yaml:"scope,omitempty" json:"scope,omitempty"`
+	// Mode is line (default) or file.
+	// In file mode, the first matcher should be r (and tmpl) and it runs across the content of a file with multiline flag.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+}
+
+const (
+	NodeModeLine = "line"
+	NodeModeFile = "file"
+)
+
+// BlockScope is a block of lines between the begin line and the end line, exclusive.
+type BlockScope struct {
+	Begin *Regexp `yaml:"begin" json:"begin"`
+	End   *Regexp `yaml:"end" json:"end"`
 }
 
 func (s NSelector) Validate() error {
+	if s.Scope != nil && (s.Scope.Begin == nil || s.Scope.End == nil) {
+		return fmt.Errorf("%w: node(%s) scope requires begin and end", ErrInvalidConfig, s.Name)
+	}
+	switch s.Mode {
+	case "", NodeModeLine:
+	case NodeModeFile:
+		if s.Scope != nil {
+			return fmt.Errorf("%w: node(%s) scope is not available in file mode", ErrInvalidConfig, s.Name)
+		}
+		if len(s.Matcher) == 0 || s.Matcher[0].Regex == nil {
+			return fmt.Errorf("%w: node(%s) file mode requires r as the first matcher", ErrInvalidConfig, s.Name)
+		}
+	default:
+		return fmt.Errorf("%w: node(%s) unknown mode %s", ErrInvalidConfig, s.Name, s.Mode)
+	}
 	for i, x := range s.Matcher {
 		if err := x.Validate(); err != nil {
 			return fmt.Errorf("%w: node(%s) selector[%d]", err, s.Name, i)
@@ -306,6 +765,89 @@ func (s NSelector) Validate() error {
 	return nil
 }
 
+type Continuation struct {
+	Name     string `yaml:"name,omitempty" json:"name,omitempty"`
+	Category Regexp `yaml:"category" json:"category"`
+	// Suffix joins a line ending with it and the next line, e.g. backslash.
+	Suffix string `yaml:"suffix,omitempty" json:"suffix,omitempty"`
+	// Brackets joins lines until opened brackets are closed.
+	Brackets bool `yaml:"brackets,omitempty" json:"brackets,omitempty"`
+}
+
+func (c Continuation) Validate() error {
+	if c.Suffix == "" && !c.Brackets {
+		return fmt.Errorf("%w: continuation(%s) requires suffix or brackets", ErrInvalidConfig, c.Name)
+	}
+	return nil
+}
+
+type Comment struct {
+	Name     string `yaml:"name,omitempty" json:"name,omitempty"`
+	Category Regexp `yaml:"category" json:"category"`
+	// Language is a builtin comment syntax, see CommentLanguages.
+	Language string `yaml:"language,omitempty" json:"language,omitempty"`
+	// Line holds the prefixes of line comments, e.g. #.
+	Line []string `yaml:"line,omitempty" json:"line,omitempty"`
+	// Block holds the delimiters of block comments, e.g. /* and */.
+	Block []CommentBlock `yaml:"block,omitempty" json:"block,omitempty"`
+	// Quote holds the delimiters of string literals.
+	Quote []string `yaml:"quote,omitempty" json:"quote,omitempty"`
+	// RawQuote holds the delimiters of string literals without escapes, e.g. ' in shell.
+	RawQuote []string `yaml:"raw_quote,omitempty" json:"raw_quote,omitempty"`
+	// WordStart means line comments start only at the beginning of a word, e.g. # in shell.
+	WordStart bool `yaml:"word_start,omitempty" json:"word_start,omitempty"`
+	// Action is drop (default) or tag.
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+}
+
+// Syntax returns the builtin syntax of the language extended by line, block and quote.
+func (c Comment) Syntax() CommentSyntax {
+	return commentLanguages[c.Language].add(CommentSyntax{
+		Line:      c.Line,
+		Block:     c.Block,
+		Quote:     c.Quote,
+		RawQuote:  c.RawQuote,
+		WordStart: c.WordStart,
+	})
+}
+
+func (c Comment) Validate() error {
+	if c.Language != "" {
+		if _, ok := commentLanguages[c.Language]; !ok {
+			return fmt.Errorf("%w: comment(%s) unknown language %s", ErrInvalidConfig, c.Name, c.Language)
+		}
+	}
+	if c.Language == "" && len(c.Line) == 0 && len(c.Block) == 0 {
+		return fmt.Errorf("%w: comment(%s) requires language, line or block", ErrInvalidConfig, c.Name)
+	}
+	for _, x := range c.Line {
+		if x == "" {
+			return fmt.Errorf("%w: comment(%s) empty line", ErrInvalidConfig, c.Name)
+		}
+	}
+	for _, x := range c.Block {
+		if x.Begin == "" || x.End == "" {
+			return fmt.Errorf("%w: comment(%s) block requires begin and end", ErrInvalidConfig, c.Name)
+		}
+	}
+	for _, x := range c.Quote {
+		if x == "" {
+			return fmt.Errorf("%w: comment(%s) empty quote", ErrInvalidConfig, c.Name)
+		}
+	}
+	for _, x := range c.RawQuote {
+		if x == "" {
+			return fmt.Errorf("%w: comment(%s) empty raw_quote", ErrInvalidConfig, c.Name)
+		}
+	}
+	switch c.Action {
+	case "", CommentActionDrop, CommentActionTag:
+		return nil
+	default:
+		return fmt.Errorf("%w: comment(%s) unknown action %s", ErrInvalidConfig, c.Name, c.Action)
+	}
+}
+
 type Regexp regexp.Regexp
 
 func NewRegexp(pattern string) Regexp {
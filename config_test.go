@@ -1,6 +1,8 @@
 package grdep_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/berquerant/grdep"
@@ -148,6 +150,26 @@ func TestConfig(t *testing.T) {
 	}
 	emptyRegexp := newRegexp(``)
 
+	t.Run("Config", generateValidateTestFunc([]validateTestcase{
+		{
+			name:   "empty",
+			target: &grdep.Config{},
+		},
+		{
+			name: "category mode",
+			target: &grdep.Config{
+				CategoryMode: grdep.CategoryModePriority,
+			},
+		},
+		{
+			name: "unknown category mode",
+			target: &grdep.Config{
+				CategoryMode: "last",
+			},
+			err: true,
+		},
+	}))
+
 	t.Run("Matcher", generateValidateTestFunc([]validateTestcase{
 		{
 			name:   "nothing",
@@ -219,11 +241,38 @@ func TestConfig(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "lua hooks",
+			target: &grdep.Matcher{
+				Lua:      `lua`,
+				LuaHooks: true,
+			},
+		},
+		{
+			name: "lua hooks and entrypoint",
+			target: &grdep.Matcher{
+				Lua:           `lua`,
+				LuaEntryPoint: "e",
+				LuaHooks:      true,
+			},
+			err: true,
+		},
+		{
+			name: "lua hooks without script",
+			target: &grdep.Matcher{
+				LuaHooks: true,
+			},
+			err: true,
+		},
 	}))
 
 	emptyMatcher := &grdep.Matcher{
 		Regex: emptyRegexp,
 	}
+	var (
+		maxSize  int64 = 1024
+		negative int64 = -1
+	)
 	t.Run("CSelector", generateValidateTestFunc([]validateTestcase{
 		{
 			name:   "empty",
@@ -250,5 +299,282 @@ func TestConfig(t *testing.T) {
 				Text: []*grdep.Matcher{emptyMatcher},
 			},
 		},
+		{
+			name: "text with limits",
+			target: &grdep.CSelector{
+				Text:     []*grdep.Matcher{emptyMatcher},
+				MaxLines: 10,
+				MaxBytes: 1024,
+			},
+		},
+		{
+			name: "head only and max lines",
+			target: &grdep.CSelector{
+				Text:     []*grdep.Matcher{emptyMatcher},
+				HeadOnly: true,
+				MaxLines: 10,
+			},
+			err: true,
+		},
+		{
+			name: "dirname and stat",
+			target: &grdep.CSelector{
+				Dirname: []*grdep.Matcher{emptyMatcher},
+				Stat: &grdep.StatSelector{
+					MaxSize:       &maxSize,
+					ModifiedAfter: "720h",
+				},
+			},
+		},
+		{
+			name: "negative size",
+			target: &grdep.CSelector{
+				Dirname: []*grdep.Matcher{emptyMatcher},
+				Stat: &grdep.StatSelector{
+					MinSize: &negative,
+				},
+			},
+			err: true,
+		},
+		{
+			name: "mime",
+			target: &grdep.CSelector{
+				Mime: []*grdep.Matcher{emptyMatcher},
+			},
+		},
+		{
+			name: "marker",
+			target: &grdep.CSelector{
+				Marker: &grdep.MarkerSelector{
+					Files: []string{"go.mod"},
+				},
+			},
+		},
+		{
+			name: "marker with path",
+			target: &grdep.CSelector{
+				Marker: &grdep.MarkerSelector{
+					Files: []string{"a/go.mod"},
+				},
+			},
+			err: true,
+		},
+		{
+			name: "gitattributes",
+			target: &grdep.CSelector{
+				GitAttributes: &grdep.GitAttributesSelector{
+					Attr: "linguist-language",
+				},
+			},
+		},
+		{
+			name: "gitattributes without attr",
+			target: &grdep.CSelector{
+				GitAttributes: &grdep.GitAttributesSelector{},
+			},
+			err: true,
+		},
+		{
+			name: "basename and symlink",
+			target: &grdep.CSelector{
+				Basename: []*grdep.Matcher{emptyMatcher},
+				Symlink:  []*grdep.Matcher{emptyMatcher},
+			},
+			err: true,
+		},
+		{
+			name: "invalid modified before",
+			target: &grdep.CSelector{
+				Basename: []*grdep.Matcher{emptyMatcher},
+				Stat: &grdep.StatSelector{
+					ModifiedBefore: "yesterday",
+				},
+			},
+			err: true,
+		},
+		{
+			name: "filename with limits",
+			target: &grdep.CSelector{
+				Filename: []*grdep.Matcher{emptyMatcher},
+				HeadOnly: true,
+			},
+			err: true,
+		},
+	}))
+
+	t.Run("NSelector", generateValidateTestFunc([]validateTestcase{
+		{
+			name: "matcher",
+			target: &grdep.NSelector{
+				Matcher: []*grdep.Matcher{emptyMatcher},
+			},
+		},
+		{
+			name: "scope",
+			target: &grdep.NSelector{
+				Matcher: []*grdep.Matcher{emptyMatcher},
+				Scope: &grdep.BlockScope{
+					Begin: emptyRegexp,
+					End:   emptyRegexp,
+				},
+			},
+		},
+		{
+			name: "scope without end",
+			target: &grdep.NSelector{
+				Matcher: []*grdep.Matcher{emptyMatcher},
+				Scope: &grdep.BlockScope{
+					Begin: emptyRegexp,
+				},
+			},
+			err: true,
+		},
+	}))
+
+	t.Run("Comment", generateValidateTestFunc([]validateTestcase{
+		{
+			name:   "empty",
+			target: &grdep.Comment{},
+			err:    true,
+		},
+		{
+			name: "language",
+			target: &grdep.Comment{
+				Language: "shell",
+			},
+		},
+		{
+			name: "unknown language",
+			target: &grdep.Comment{
+				Language: "unknown",
+			},
+			err: true,
+		},
+		{
+			name: "line",
+			target: &grdep.Comment{
+				Line:   []string{"#"},
+				Action: grdep.CommentActionTag,
+			},
+		},
+		{
+			name: "block without end",
+			target: &grdep.Comment{
+				Block: []grdep.CommentBlock{{Begin: "/*"}},
+			},
+			err: true,
+		},
+		{
+			name: "unknown action",
+			target: &grdep.Comment{
+				Language: "shell",
+				Action:   "remove",
+			},
+			err: true,
+		},
 	}))
 }
+
+func TestConfigSandbox(t *testing.T) {
+	c, err := grdep.ParseConfig(`category:
+  - name: sh
+    filename:
+      - sh: "cat"
+node:
+  - name: lua
+    category: ".*"
+    matcher:
+      - lua: |
+          function f(src)
+            return os.getenv("HOME")
+          end
+        lua_call: f`)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Len(t, c.Hash(), 64)
+
+	assert.Equal(t, []grdep.SandboxedMatcher{
+		{
+			Location:    "category(sh) filename[0]",
+			Restriction: grdep.SandboxBlocked,
+		},
+		{
+			Location:    "node(lua) matcher[0]",
+			Restriction: grdep.SandboxRestricted,
+		},
+	}, c.Sandbox())
+	_, err = c.Categories[0].Filename[0].Match("x")
+	assert.ErrorIs(t, err, grdep.ErrExecNotAllowed)
+	// a set of matchers keeps it to report the blocked matcher
+	_, err = grdep.MatcherSet(c.Categories[0].Filename).Match("x")
+	assert.ErrorIs(t, err, grdep.ErrExecNotAllowed)
+	assert.ErrorIs(t, err, grdep.ErrUnmatched)
+	_, err = c.Nodes[0].Matcher[0].Match("x")
+	assert.ErrorIs(t, err, grdep.ErrLuaInvalidCall)
+}
+
+func TestConfigHashLuaFile(t *testing.T) {
+	dir := t.TempDir()
+	var (
+		script = filepath.Join(dir, "f.lua")
+		config = filepath.Join(dir, "config.yml")
+	)
+	writeFile := func(name, content string) bool {
+		return assert.Nil(t, os.WriteFile(name, []byte(content), 0644))
+	}
+	if !writeFile(config, `category:
+  - filename:
+      - lua_file: `+script+`
+        lua_call: f
+node: []`) {
+		return
+	}
+	if !writeFile(script, `function f(src) return "a" end`) {
+		return
+	}
+
+	c1, err := grdep.ParseConfig(config)
+	if !assert.Nil(t, err) {
+		return
+	}
+	if !writeFile(script, `function f(src) return "b" end`) {
+		return
+	}
+	c2, err := grdep.ParseConfig(config)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.NotEqual(t, c1.Hash(), c2.Hash())
+
+	// The script read when the config is parsed runs.
+	got, err := c1.Categories[0].Filename[0].Match("x")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, got)
+
+	t.Run("modules", func(t *testing.T) {
+		if !assert.Nil(t, os.MkdirAll(filepath.Join(dir, "lib"), 0755)) {
+			return
+		}
+		hash := func() string {
+			c, err := grdep.ParseConfig(config)
+			assert.Nil(t, err)
+			return c.Hash()
+		}
+		if !writeFile(filepath.Join(dir, "lib", "util.lua"), `return {}`) {
+			return
+		}
+		h1 := hash()
+		if !writeFile(filepath.Join(dir, "lib", "util.lua"), `return {x = 1}`) {
+			return
+		}
+		h2 := hash()
+		assert.NotEqual(t, h1, h2)
+
+		// not resolved by require
+		if !writeFile(filepath.Join(dir, "README.md"), `readme`) {
+			return
+		}
+		assert.Equal(t, h2, hash())
+	})
+}
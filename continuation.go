@@ -0,0 +1,83 @@
+package grdep
+
+import "strings"
+
+type Continuations []Continuation
+
+// NewLineJoiner returns a joiner of the first continuation that matches the category,
+// nil if there is no match.
+func (c Continuations) NewLineJoiner(category string) *LineJoiner {
+	for _, x := range c {
+		if x.Category.Unwrap().MatchString(category) {
+			return NewLineJoiner(x.Suffix, x.Brackets)
+		}
+	}
+	return nil
+}
+
+func NewLineJoiner(suffix string, brackets bool) *LineJoiner {
+	return &LineJoiner{
+		suffix:   suffix,
+		brackets: brackets,
+	}
+}
+
+// LineJoiner joins physical lines into logical lines.
+// Continuation lines are trimmed and joined by a space.
+type LineJoiner struct {
+	suffix   string
+	brackets bool
+
+	pending *Line
+	depth   int
+}
+
+// Join adds a physical line, returns a logical line if it is completed.
+func (j *LineJoiner) Join(line Line) (Line, bool) {
+	content, cont := j.continued(line.Content)
+	if j.pending == nil {
+		x := line
+		x.Content = content
+		j.pending = &x
+	} else {
+		if s := strings.TrimSpace(content); s != "" {
+			if j.pending.Content != "" {
+				j.pending.Content += " "
+			}
+			j.pending.Content += s
+		}
+		j.pending.EndLinum = line.Linum
+	}
+
+	if cont {
+		return Line{}, false
+	}
+	return j.Flush()
+}
+
+// Flush returns the pending logical line.
+func (j *LineJoiner) Flush() (Line, bool) {
+	if j.pending == nil {
+		return Line{}, false
+	}
+	x := *j.pending
+	j.pending = nil
+	j.depth = 0
+	return x, true
+}
+
+// continued returns the content without the continuation suffix and true if the next line continues.
+func (j *LineJoiner) continued(content string) (string, bool) {
+	var cont bool
+	if j.brackets {
+		j.depth += strings.Count(content, "(") + strings.Count(content, "[") + strings.Count(content, "{")
+		j.depth -= strings.Count(content, ")") + strings.Count(content, "]") + strings.Count(content, "}")
+		cont = j.depth > 0
+	}
+	if j.suffix != "" {
+		if s := strings.TrimRight(content, " \t"); strings.HasSuffix(s, j.suffix) {
+			return strings.TrimRight(strings.TrimSuffix(s, j.suffix), " \t"), true
+		}
+	}
+	return content, cont
+}
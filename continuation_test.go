@@ -0,0 +1,90 @@
+package grdep_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/grdep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineJoiner(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		suffix   string
+		brackets bool
+		lines    []string
+		want     []grdep.Line
+	}{
+		{
+			name:   "no continuation",
+			suffix: `\`,
+			lines:  []string{"a", "b"},
+			want: []grdep.Line{
+				{Linum: 1, Content: "a"},
+				{Linum: 2, Content: "b"},
+			},
+		},
+		{
+			name:   "suffix",
+			suffix: `\`,
+			lines: []string{
+				`RUN apt-get install \`,
+				`    curl \`,
+				`    git`,
+				`ENTRYPOINT ["curl"]`,
+			},
+			want: []grdep.Line{
+				{Linum: 1, EndLinum: 3, Content: "RUN apt-get install curl git"},
+				{Linum: 4, Content: `ENTRYPOINT ["curl"]`},
+			},
+		},
+		{
+			name:   "suffix at eof",
+			suffix: `\`,
+			lines:  []string{`a \`, `b \`},
+			want: []grdep.Line{
+				{Linum: 1, EndLinum: 2, Content: "a b"},
+			},
+		},
+		{
+			name:     "brackets",
+			brackets: true,
+			lines: []string{
+				"requirements = [",
+				`  "a",`,
+				`  "b",`,
+				"]",
+				"x = (1)",
+			},
+			want: []grdep.Line{
+				{Linum: 1, EndLinum: 4, Content: `requirements = [ "a", "b", ]`},
+				{Linum: 5, Content: "x = (1)"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			j := grdep.NewLineJoiner(tc.suffix, tc.brackets)
+			got := []grdep.Line{}
+			for i, x := range tc.lines {
+				if line, ok := j.Join(grdep.Line{Linum: i + 1, Content: x}); ok {
+					got = append(got, line)
+				}
+			}
+			if line, ok := j.Flush(); ok {
+				got = append(got, line)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("Continuations", func(t *testing.T) {
+		c := grdep.Continuations{
+			{
+				Category: grdep.NewRegexp(`^dockerfile$`),
+				Suffix:   `\`,
+			},
+		}
+		assert.NotNil(t, c.NewLineJoiner("dockerfile"))
+		assert.Nil(t, c.NewLineJoiner("go"))
+	})
+}
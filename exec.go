@@ -6,12 +6,17 @@ import (
 	"errors"
 	"io"
 	"strings"
+	"sync"
 
 	"github.com/berquerant/execx"
 )
 
+// ShellScript runs a script with a shell.
+// Runs are serialized because execx.Script is not documented as safe for concurrent use,
+// different scripts run concurrently.
 type ShellScript struct {
 	script *execx.Script
+	mux    sync.Mutex
 }
 
 func NewShellScript(content, shell string) *ShellScript {
@@ -28,7 +33,10 @@ var (
 	ErrShellReadStdout = errors.New("ShellReadStdout")
 )
 
-func (s ShellScript) Run(ctx context.Context, src string) ([]string, error) {
+func (s *ShellScript) Run(ctx context.Context, src string) ([]string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
 	var result []string
 	if err := s.script.Runner(func(cmd *execx.Cmd) error {
 		cmd.Stdin = bytes.NewBufferString(src)
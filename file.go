@@ -0,0 +1,412 @@
+package grdep
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"iter"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// File is a file to find dependencies.
+//
+// The content is read only once in most cases:
+// lines read by Head are buffered up to maxHeadBytes and Lines replays them before reading the rest,
+// so text category selectors and node selectors share a single read.
+// The content is opened again only when the lines beyond the buffer are read again.
+// File is not safe for concurrent use.
+type File struct {
+	Path string
+	// RealPath is the path with symlinks resolved, empty unless symlinks are followed.
+	RealPath string
+	// Layer is the digest of the layer of the container image that provides the file.
+	Layer string
+	// Root is the root of the walk that yields the file.
+	Root string
+	// Record is the 1-based index of the record that provides the file, 0 unless the file is given by RecordWalker.
+	Record int
+	// Compression is the compression of the content like gzip, empty if not compressed.
+	// It is known after the content is opened.
+	Compression string
+	// Info is nil if unknown.
+	Info fs.FileInfo
+	// Vars are the variables of the file set by category selectors, e.g. project_root.
+	Vars map[string]string
+
+	// targetInfo is the metadata of the target of the symlink.
+	targetInfo fs.FileInfo
+	open       func() (io.ReadCloser, error)
+	rc         io.ReadCloser
+	reader     *bufio.Reader
+	sniffed    []byte // first bytes of the content
+	openErr    error
+	lines      *LineReader
+	// lineOptions are the options to read lines.
+	lineOptions []LineReaderOption
+	read        int // number of lines read from the content
+	head        []ReadLinesResult
+	headBytes   int // bytes of head
+	eof         bool
+}
+
+// maxHeadBytes is the max bytes of the lines buffered by Head.
+const maxHeadBytes = 1 << 20
+
+// NewFile returns a file that reads the content by open lazily.
+func NewFile(path string, info fs.FileInfo, open func() (io.ReadCloser, error)) *File {
+	return &File{
+		Path: path,
+		Info: info,
+		open: open,
+	}
+}
+
+// newErrFile returns a file that fails to read by err.
+func newErrFile(path string, err error) *File {
+	return NewFile(path, nil, func() (io.ReadCloser, error) {
+		return nil, err
+	})
+}
+
+// OpenFile returns a file on the filesystem.
+func OpenFile(path string, info fs.FileInfo) *File {
+	return NewFile(path, info, func() (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+}
+
+// SetLineReaderOptions sets the options to read lines, it should be called before reading lines.
+func (f *File) SetLineReaderOptions(opt ...LineReaderOption) {
+	f.lineOptions = opt
+}
+
+func (f *File) SetVar(name, value string) {
+	if f.Vars == nil {
+		f.Vars = map[string]string{}
+	}
+	f.Vars[name] = value
+}
+
+// Stat returns Info, calls lstat if it is unknown.
+func (f *File) Stat() (fs.FileInfo, error) {
+	if f.Info != nil {
+		return f.Info, nil
+	}
+	info, err := os.Lstat(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	f.Info = info
+	return info, nil
+}
+
+// TargetStat returns the metadata of the file that the symlink points to, or Stat if the file is not a symlink.
+func (f *File) TargetStat() (fs.FileInfo, error) {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		return info, err
+	}
+	if f.targetInfo != nil {
+		return f.targetInfo, nil
+	}
+	target, err := os.Stat(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	f.targetInfo = target
+	return target, nil
+}
+
+// sniffLen is the number of bytes to detect the content type.
+const sniffLen = 512
+
+// openContent opens the content and sniffs the first bytes if not yet.
+func (f *File) openContent() error {
+	if f.reader != nil || f.openErr != nil {
+		return f.openErr
+	}
+	if f.eof {
+		return os.ErrClosed
+	}
+
+	rc, err := f.open()
+	if err != nil {
+		f.openErr = err
+		return err
+	}
+	AddMetricCount("file-open", 1)
+	f.rc = rc
+	f.reader = bufio.NewReader(rc)
+	if err := f.decompress(); err != nil {
+		f.openErr = err
+		return err
+	}
+	f.reader = bufio.NewReader(decodeBOM(f.reader))
+
+	b, err := f.reader.Peek(sniffLen)
+	if err != nil && !errors.Is(err, io.EOF) {
+		f.openErr = err
+		return err
+	}
+	f.sniffed = bytes.Clone(b)
+	return nil
+}
+
+const (
+	CompressionGzip  = "gzip"
+	CompressionBzip2 = "bzip2"
+)
+
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicBzip2 = []byte("BZh")
+)
+
+// compressionSuffixes are the suffixes of the compressed files to remove from the name.
+var compressionSuffixes = map[string][]string{
+	CompressionGzip:  {".gz", ".gzip"},
+	CompressionBzip2: {".bz2", ".bzip2"},
+}
+
+// hasCompressionSuffix returns true if the lowercase path ends with any of the compressionSuffixes.
+func hasCompressionSuffix(lowerPath string) bool {
+	for _, xs := range compressionSuffixes {
+		for _, x := range xs {
+			if strings.HasSuffix(lowerPath, x) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decompress replaces the reader with the decompressed one by the magic bytes.
+func (f *File) decompress() error {
+	b, _ := f.reader.Peek(4)
+	switch {
+	case bytes.HasPrefix(b, magicGzip):
+		r, err := gzip.NewReader(f.reader)
+		if err != nil {
+			return err
+		}
+		f.Compression = CompressionGzip
+		f.reader = bufio.NewReader(r)
+	case bytes.HasPrefix(b, magicBzip2) && len(b) == 4 && b[3] >= '1' && b[3] <= '9':
+		f.Compression = CompressionBzip2
+		f.reader = bufio.NewReader(bzip2.NewReader(f.reader))
+	default:
+		return nil
+	}
+	AddMetricCount("file-decompress", 1)
+	return nil
+}
+
+// Name returns the path without the compression suffix if the content is compressed,
+// e.g. start.sh for start.sh.gz. Category selectors use it as the path.
+// The content is not opened unless the path has one of the suffixes.
+func (f *File) Name() string {
+	lower := strings.ToLower(f.Path)
+	if !hasCompressionSuffix(lower) {
+		return f.Path
+	}
+	if err := f.openContent(); err != nil || f.Compression == "" {
+		return f.Path
+	}
+	for _, x := range compressionSuffixes[f.Compression] {
+		if strings.HasSuffix(lower, x) {
+			return f.Path[:len(f.Path)-len(x)]
+		}
+	}
+	return f.Path
+}
+
+// Sniff returns the first bytes of the content, up to 512 bytes.
+func (f *File) Sniff() ([]byte, error) {
+	if err := f.openContent(); err != nil {
+		return nil, err
+	}
+	return f.sniffed, nil
+}
+
+// ContentType returns the MIME type of the content detected by http.DetectContentType.
+func (f *File) ContentType() (string, error) {
+	b, err := f.Sniff()
+	if err != nil {
+		return "", err
+	}
+	return http.DetectContentType(b), nil
+}
+
+// IsBinary returns true if the first bytes of the content contain NUL.
+// The bytes are sniffed after decoding by the BOM, so UTF-16 text without BOM is binary.
+func (f *File) IsBinary() (bool, error) {
+	b, err := f.Sniff()
+	if err != nil {
+		return false, err
+	}
+	return bytes.IndexByte(b, 0) >= 0, nil
+}
+
+// Close closes the content, no more lines are read.
+func (f *File) Close() error {
+	f.eof = true
+	if f.rc == nil {
+		return nil
+	}
+	err := f.rc.Close()
+	f.rc = nil
+	return err
+}
+
+// next reads the next line from the content.
+// An error is the last line.
+func (f *File) next() (ReadLinesResult, bool) {
+	if f.eof {
+		return ReadLinesResult{}, false
+	}
+	if f.lines == nil {
+		if err := f.openContent(); err != nil {
+			f.eof = true
+			return ReadLinesResult{Err: err}, true
+		}
+		f.lines = newLineReader(f.reader, f.lineOptions...)
+	}
+
+	x, err := f.lines.Next()
+	if err == nil {
+		f.read++
+		return x, true
+	}
+	_ = f.Close()
+	if errors.Is(err, io.EOF) {
+		return ReadLinesResult{}, false
+	}
+	return ReadLinesResult{Err: err}, true
+}
+
+// Head returns the lines from the beginning.
+// The lines read are buffered for Lines up to maxHeadBytes.
+func (f *File) Head() iter.Seq[ReadLinesResult] {
+	return func(yield func(ReadLinesResult) bool) {
+		for i := 0; ; i++ {
+			x, ok := f.lineAt(i)
+			if !ok {
+				return
+			}
+			if !yield(x) {
+				return
+			}
+		}
+	}
+}
+
+// lineAt returns the i-th line from 0, the line is buffered if it is next to the buffer and the buffer is not full.
+func (f *File) lineAt(i int) (ReadLinesResult, bool) {
+	if i < len(f.head) {
+		return f.head[i], true
+	}
+	if x, ok := f.skipTo(i); !ok {
+		return x, x.Err != nil
+	}
+	x, ok := f.next()
+	if !ok {
+		return x, false
+	}
+	if i == len(f.head) && (x.Err != nil || f.headBytes+len(x.Text) <= maxHeadBytes) {
+		f.head = append(f.head, x)
+		f.headBytes += len(x.Text)
+	}
+	return x, true
+}
+
+// skipTo makes the i-th line from 0 the next line to read, the content is opened again if the line is already read.
+// Returns false and the error line if any when the line cannot be reached.
+func (f *File) skipTo(i int) (ReadLinesResult, bool) {
+	if f.read > i {
+		f.reset()
+	}
+	for f.read < i {
+		x, ok := f.next()
+		if !ok || x.Err != nil {
+			return x, false
+		}
+	}
+	return ReadLinesResult{}, true
+}
+
+// reset closes the content to read it again from the beginning.
+func (f *File) reset() {
+	AddMetricCount("file-reopen", 1)
+	if f.rc != nil {
+		_ = f.rc.Close()
+	}
+	f.rc = nil
+	f.reader = nil
+	f.lines = nil
+	f.openErr = nil
+	f.eof = false
+	f.read = 0
+}
+
+// Lines returns all the lines and closes the content.
+// The buffer of Head is released, so Head and Lines should not be called after Lines.
+func (f *File) Lines(ctx context.Context) iter.Seq[Line] {
+	return func(yield func(Line) bool) {
+		defer f.Close()
+
+		head := f.head
+		f.head = nil
+		f.headBytes = 0
+		for _, x := range head {
+			if !yield(f.intoLine(x)) {
+				return
+			}
+		}
+		if x, ok := f.skipTo(len(head)); !ok {
+			if x.Err != nil {
+				yield(f.intoLine(x))
+			}
+			return
+		}
+
+		for {
+			if IsDone(ctx) {
+				yield(Line{
+					Path:        f.Path,
+					RealPath:    f.RealPath,
+					Layer:       f.Layer,
+					Err:         ctx.Err(),
+					Compression: f.Compression,
+				})
+				return
+			}
+			x, ok := f.next()
+			if !ok {
+				return
+			}
+			if !yield(f.intoLine(x)) {
+				return
+			}
+		}
+	}
+}
+
+func (f *File) intoLine(x ReadLinesResult) Line {
+	return Line{
+		Linum:       x.Linum,
+		Content:     x.Text,
+		Truncated:   x.Truncated,
+		Path:        f.Path,
+		RealPath:    f.RealPath,
+		Layer:       f.Layer,
+		Err:         x.Err,
+		Compression: f.Compression,
+	}
+}
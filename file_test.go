@@ -0,0 +1,235 @@
+package grdep_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/grdep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFile(t *testing.T) {
+	newFile := func(content string, opened *int) *grdep.File {
+		return grdep.NewFile("f", nil, func() (io.ReadCloser, error) {
+			*opened++
+			return io.NopCloser(bytes.NewBufferString(content)), nil
+		})
+	}
+	readLines := func(f *grdep.File) []grdep.Line {
+		got := []grdep.Line{}
+		for x := range f.Lines(context.TODO()) {
+			got = append(got, x)
+		}
+		return got
+	}
+
+	t.Run("Lines", func(t *testing.T) {
+		var opened int
+		f := newFile("a\nb", &opened)
+		assert.Equal(t, []grdep.Line{
+			{Linum: 1, Content: "a", Path: "f"},
+			{Linum: 2, Content: "b", Path: "f"},
+		}, readLines(f))
+		assert.Equal(t, 1, opened)
+	})
+
+	t.Run("HeadAndLines", func(t *testing.T) {
+		var opened int
+		f := newFile("a\nb\nc", &opened)
+		for range 2 {
+			got := []string{}
+			for x := range f.Head() {
+				got = append(got, x.Text)
+				if x.Text == "b" {
+					break
+				}
+			}
+			assert.Equal(t, []string{"a", "b"}, got)
+		}
+		assert.Equal(t, []grdep.Line{
+			{Linum: 1, Content: "a", Path: "f"},
+			{Linum: 2, Content: "b", Path: "f"},
+			{Linum: 3, Content: "c", Path: "f"},
+		}, readLines(f))
+		assert.Equal(t, 1, opened)
+	})
+
+	t.Run("HeadOverBuffer", func(t *testing.T) {
+		var (
+			opened int
+			lines  = make([]string, 3000)
+		)
+		for i := range lines {
+			lines[i] = fmt.Sprintf("%04d%s", i, strings.Repeat("x", 1000))
+		}
+		f := newFile(strings.Join(lines, "\n"), &opened)
+		for range 2 {
+			got := []string{}
+			for x := range f.Head() {
+				got = append(got, x.Text)
+			}
+			assert.Equal(t, lines, got)
+		}
+		got := []string{}
+		for i, x := range readLines(f) {
+			assert.Equal(t, i+1, x.Linum)
+			got = append(got, x.Content)
+		}
+		assert.Equal(t, lines, got)
+		// the lines beyond the buffer are read again
+		assert.Equal(t, 3, opened)
+	})
+
+	t.Run("OpenError", func(t *testing.T) {
+		errOpen := errors.New("Open")
+		f := grdep.NewFile("f", nil, func() (io.ReadCloser, error) {
+			return nil, errOpen
+		})
+		var got []error
+		for x := range f.Head() {
+			got = append(got, x.Err)
+		}
+		assert.Equal(t, []error{errOpen}, got)
+		assert.Equal(t, []grdep.Line{
+			{Path: "f", Err: errOpen},
+		}, readLines(f))
+	})
+
+	t.Run("Sniff", func(t *testing.T) {
+		for _, tc := range []struct {
+			name        string
+			content     string
+			text        string // decoded content if not the same as content
+			binary      bool
+			contentType string
+		}{
+			{
+				name:        "empty",
+				contentType: "text/plain; charset=utf-8",
+			},
+			{
+				name:        "text",
+				content:     "#!/bin/bash\necho a",
+				contentType: "text/plain; charset=utf-8",
+			},
+			{
+				name:        "html",
+				content:     "<html><body></body></html>",
+				contentType: "text/html; charset=utf-8",
+			},
+			{
+				name:        "gif",
+				content:     "GIF89a\x00\x00",
+				binary:      true,
+				contentType: "image/gif",
+			},
+			{
+				name:        "utf-16 with bom",
+				content:     "\xff\xfea\x00\n\x00",
+				text:        "a\n",
+				contentType: "text/plain; charset=utf-8",
+			},
+			{
+				name:        "utf-16 without bom",
+				content:     "a\x00\n\x00",
+				binary:      true,
+				contentType: "application/octet-stream",
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				var opened int
+				f := newFile(tc.content, &opened)
+				binary, err := f.IsBinary()
+				assert.Nil(t, err)
+				assert.Equal(t, tc.binary, binary)
+				contentType, err := f.ContentType()
+				assert.Nil(t, err)
+				assert.Equal(t, tc.contentType, contentType)
+				// sniffing does not consume lines
+				var content []string
+				for x := range f.Lines(context.TODO()) {
+					content = append(content, x.Content)
+				}
+				want := tc.content
+				if tc.text != "" {
+					want = tc.text
+				}
+				assert.Equal(t, strings.TrimSuffix(want, "\n"), strings.Join(content, "\n"))
+				assert.Equal(t, 1, opened)
+			})
+		}
+	})
+	t.Run("Decompress", func(t *testing.T) {
+		var gz bytes.Buffer
+		w := gzip.NewWriter(&gz)
+		_, _ = w.Write([]byte("a\nb"))
+		_ = w.Close()
+		// printf 'a\nb' | bzip2
+		bz := "BZh91AY&SY\x8b\xe0\xbf\xfc\x00\x00\x00\xc1\x00\x00\x10\x30\x00\x20\x00\x21\x98\x19\x81\x61\x77\x24\x53\x85\x09\x08\xbe\x0b\xff\xc0"
+
+		for _, tc := range []struct {
+			name        string
+			path        string
+			content     string
+			wantName    string
+			compression string
+		}{
+			{
+				name:        "gzip",
+				path:        "start.sh.gz",
+				content:     gz.String(),
+				wantName:    "start.sh",
+				compression: grdep.CompressionGzip,
+			},
+			{
+				name:        "bzip2",
+				path:        "app.conf.BZ2",
+				content:     bz,
+				wantName:    "app.conf",
+				compression: grdep.CompressionBzip2,
+			},
+			{
+				name:        "gzip without suffix",
+				path:        "start",
+				content:     gz.String(),
+				wantName:    "start",
+				compression: grdep.CompressionGzip,
+			},
+			{
+				name:     "not compressed",
+				path:     "start.sh.gz",
+				content:  "a\nb",
+				wantName: "start.sh.gz",
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				f := grdep.NewFile(tc.path, nil, func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewBufferString(tc.content)), nil
+				})
+				assert.Equal(t, tc.wantName, f.Name())
+				binary, err := f.IsBinary()
+				assert.Nil(t, err)
+				assert.False(t, binary)
+				assert.Equal(t, []grdep.Line{
+					{Linum: 1, Content: "a", Path: tc.path, Compression: tc.compression},
+					{Linum: 2, Content: "b", Path: tc.path, Compression: tc.compression},
+				}, readLines(f))
+			})
+		}
+		t.Run("name without suffix", func(t *testing.T) {
+			var opened int
+			f := grdep.NewFile("start", nil, func() (io.ReadCloser, error) {
+				opened++
+				return io.NopCloser(bytes.NewBufferString(gz.String())), nil
+			})
+			assert.Equal(t, "start", f.Name())
+			assert.Equal(t, 0, opened)
+		})
+	})
+}
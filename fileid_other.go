@@ -0,0 +1,27 @@
+//go:build !unix
+
+package grdep
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// fileID identifies a file by the absolute path with symlinks resolved.
+type fileID struct {
+	path string
+}
+
+func getFileID(path string, _ fs.FileInfo) (fileID, bool) {
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fileID{}, false
+	}
+	abs, err := filepath.Abs(realPath)
+	if err != nil {
+		return fileID{}, false
+	}
+	return fileID{
+		path: abs,
+	}, true
+}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package grdep
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileID identifies a file on the filesystem.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+func getFileID(_ string, info fs.FileInfo) (fileID, bool) {
+	x, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{
+		dev: uint64(x.Dev),
+		ino: x.Ino,
+	}, true
+}
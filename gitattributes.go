@@ -0,0 +1,148 @@
+package grdep
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+const (
+	gitAttributesFile = ".gitattributes"
+	// GitAttributeSet is the value of a set attribute like "attr".
+	GitAttributeSet = "true"
+	// GitAttributeUnset is the value of an unset attribute like "-attr".
+	GitAttributeUnset = "false"
+)
+
+type gitAttributeRule struct {
+	pattern gitPattern
+	// attrs are the values of the attributes, empty value means unspecified like "!attr".
+	attrs map[string]string
+}
+
+// gitAttributeMacros are the builtin macros.
+var gitAttributeMacros = map[string]map[string]string{
+	"binary": {
+		"binary": GitAttributeSet,
+		"diff":   GitAttributeUnset,
+		"merge":  GitAttributeUnset,
+		"text":   GitAttributeUnset,
+	},
+}
+
+func parseGitAttributes(lines []string) []gitAttributeRule {
+	rules := []gitAttributeRule{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "[attr]") {
+			continue
+		}
+		pattern, ok := parseGitPattern(fields[0])
+		// negative patterns are forbidden
+		if !ok || pattern.negate {
+			continue
+		}
+
+		attrs := map[string]string{}
+		for _, x := range fields[1:] {
+			switch {
+			case strings.HasPrefix(x, "-"):
+				attrs[x[1:]] = GitAttributeUnset
+			case strings.HasPrefix(x, "!"):
+				attrs[x[1:]] = ""
+			case strings.Contains(x, "="):
+				kv := strings.SplitN(x, "=", 2)
+				attrs[kv[0]] = kv[1]
+			default:
+				if macro, ok := gitAttributeMacros[x]; ok {
+					for k, v := range macro {
+						attrs[k] = v
+					}
+					continue
+				}
+				attrs[x] = GitAttributeSet
+			}
+		}
+		rules = append(rules, gitAttributeRule{
+			pattern: pattern,
+			attrs:   attrs,
+		})
+	}
+	return rules
+}
+
+func readGitAttributes(dir string) []gitAttributeRule {
+	return parseGitAttributes(readGitLines(filepath.Join(dir, gitAttributesFile)))
+}
+
+// GitAttributes resolves attributes of files from .gitattributes in the ancestor directories
+// up to the root of the git repository.
+type GitAttributes struct {
+	rules  func(dir string) []gitAttributeRule
+	exists func(path string) bool
+}
+
+func NewGitAttributes() *GitAttributes {
+	return &GitAttributes{
+		rules:  CachedFunc(readGitAttributes),
+		exists: CachedFunc(fileExists),
+	}
+}
+
+// Get returns the attributes of the file found by the walk from root.
+// The value is "true" if set, "false" if unset, or the value, unspecified attributes are not contained.
+// Outside git repositories, the files above root are not read.
+func (g *GitAttributes) Get(root, path string) (map[string]string, error) {
+	dirs, _, err := gitRepoDirs(root, path, g.exists)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]string{}
+	// deeper files take precedence
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rules := g.rules(dirs[i].abs)
+		if len(rules) == 0 {
+			continue
+		}
+		rel, err := filepath.Rel(dirs[i].abs, abs)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		// later lines take precedence
+		for _, rule := range rules {
+			if !rule.pattern.match(rel, false) {
+				continue
+			}
+			for k, v := range rule.attrs {
+				if v == "" {
+					delete(result, k)
+					continue
+				}
+				result[k] = v
+			}
+		}
+	}
+	return result, nil
+}
+
+// Ignored returns true if any of the attributes of the file is set or has a value.
+func (g *GitAttributes) Ignored(root, path string, attrs []string) bool {
+	if len(attrs) == 0 {
+		return false
+	}
+	values, err := g.Get(root, path)
+	if err != nil {
+		return false
+	}
+	for _, x := range attrs {
+		if v, ok := values[x]; ok && v != GitAttributeUnset {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package grdep_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berquerant/grdep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitAttributes(t *testing.T) {
+	root := t.TempDir()
+	for p, content := range map[string]string{
+		".git/HEAD": "",
+		".gitattributes": `# comment
+*.sh linguist-language=Shell
+/gen.go linguist-generated
+vendor/** linguist-vendored
+docs/*.md -diff
+*.bin binary
+`,
+		"sub/.gitattributes": `*.sh linguist-language=Bash
+keep.sh !linguist-language
+b[!c].txt grdep-category=b
+`,
+		"a.sh":              "",
+		"gen.go":            "",
+		"sub/gen.go":        "",
+		"sub/a.sh":          "",
+		"sub/keep.sh":       "",
+		"sub/bd.txt":        "",
+		"sub/bc.txt":        "",
+		"vendor/x/y.go":     "",
+		"docs/readme.md":    "",
+		"docs/sub/child.md": "",
+		"data.bin":          "",
+	} {
+		p = filepath.Join(root, p)
+		if !assert.Nil(t, os.MkdirAll(filepath.Dir(p), 0755)) {
+			return
+		}
+		if !assert.Nil(t, os.WriteFile(p, []byte(content), 0644)) {
+			return
+		}
+	}
+
+	attributes := grdep.NewGitAttributes()
+
+	t.Run("Get", func(t *testing.T) {
+		for _, tc := range []struct {
+			path string
+			want map[string]string
+		}{
+			{
+				path: "a.sh",
+				want: map[string]string{"linguist-language": "Shell"},
+			},
+			{
+				path: "gen.go",
+				want: map[string]string{"linguist-generated": grdep.GitAttributeSet},
+			},
+			{
+				path: "sub/gen.go",
+				want: map[string]string{},
+			},
+			{
+				path: "sub/a.sh",
+				want: map[string]string{"linguist-language": "Bash"},
+			},
+			{
+				path: "sub/keep.sh",
+				want: map[string]string{},
+			},
+			{
+				path: "sub/bd.txt",
+				want: map[string]string{"grdep-category": "b"},
+			},
+			{
+				path: "sub/bc.txt",
+				want: map[string]string{},
+			},
+			{
+				path: "vendor/x/y.go",
+				want: map[string]string{"linguist-vendored": grdep.GitAttributeSet},
+			},
+			{
+				path: "docs/readme.md",
+				want: map[string]string{"diff": grdep.GitAttributeUnset},
+			},
+			{
+				path: "docs/sub/child.md",
+				want: map[string]string{},
+			},
+			{
+				path: "data.bin",
+				want: map[string]string{
+					"binary": grdep.GitAttributeSet,
+					"diff":   grdep.GitAttributeUnset,
+					"merge":  grdep.GitAttributeUnset,
+					"text":   grdep.GitAttributeUnset,
+				},
+			},
+		} {
+			t.Run(tc.path, func(t *testing.T) {
+				got, err := attributes.Get(root, filepath.Join(root, tc.path))
+				assert.Nil(t, err)
+				assert.Equal(t, tc.want, got)
+			})
+		}
+	})
+
+	t.Run("Ignored", func(t *testing.T) {
+		attrs := []string{"linguist-generated", "linguist-vendored", "diff"}
+		for _, tc := range []struct {
+			path string
+			want bool
+		}{
+			{path: "gen.go", want: true},
+			{path: "vendor/x/y.go", want: true},
+			{path: "sub/gen.go", want: false},
+			{path: "docs/readme.md", want: false}, // unset
+			{path: "a.sh", want: false},
+		} {
+			t.Run(tc.path, func(t *testing.T) {
+				assert.Equal(t, tc.want, attributes.Ignored(root, filepath.Join(root, tc.path), attrs))
+			})
+		}
+	})
+
+	t.Run("Selector", func(t *testing.T) {
+		for _, tc := range []struct {
+			name    string
+			path    string
+			record  int
+			matcher grdep.MatcherIface
+			want    []string
+			err     error
+		}{
+			{
+				name: "value",
+				path: "sub/a.sh",
+				want: []string{"Bash"},
+			},
+			{
+				name: "matcher",
+				path: "a.sh",
+				matcher: grdep.MatcherSet([]*grdep.Matcher{
+					{Value: []string{"shell"}},
+				}),
+				want: []string{"shell"},
+			},
+			{
+				name: "unspecified",
+				path: "sub/keep.sh",
+				err:  grdep.ErrUnmatched,
+			},
+			{
+				name:   "record",
+				path:   "sub/a.sh",
+				record: 1,
+				err:    grdep.ErrUnmatched,
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				selector := grdep.NewGitAttributeCategorySelector(attributes, "linguist-language", tc.matcher)
+				defer selector.Close()
+				f := grdep.OpenFile(filepath.Join(root, tc.path), nil)
+				f.Record = tc.record
+				got, err := selector.Select(f)
+				if tc.err != nil {
+					assert.ErrorIs(t, err, tc.err)
+					return
+				}
+				assert.Nil(t, err)
+				assert.Equal(t, tc.want, got)
+			})
+		}
+	})
+}
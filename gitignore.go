@@ -0,0 +1,100 @@
+package grdep
+
+import (
+	"path/filepath"
+)
+
+// gitIgnoreFiles are the files of ignore patterns in each directory, later ones take precedence.
+var gitIgnoreFiles = []string{".gitignore", ".ignore"}
+
+// gitExcludeFile is the file of ignore patterns of the repository, relative to the root.
+var gitExcludeFile = filepath.Join(gitDir, "info", "exclude")
+
+func parseGitIgnore(lines []string) []gitPattern {
+	patterns := []gitPattern{}
+	for _, line := range lines {
+		if p, ok := parseGitPattern(line); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+func readGitIgnore(dir string) []gitPattern {
+	var lines []string
+	for _, x := range gitIgnoreFiles {
+		lines = append(lines, readGitLines(filepath.Join(dir, x))...)
+	}
+	return parseGitIgnore(lines)
+}
+
+func readGitExclude(root string) []gitPattern {
+	return parseGitIgnore(readGitLines(filepath.Join(root, gitExcludeFile)))
+}
+
+// GitIgnore decides whether files are ignored by .gitignore and .ignore in the ancestor directories
+// up to the root of the git repository, and .git/info/exclude of the repository.
+//
+// The parents of a path are not checked,
+// so the walker should skip the ignored directories instead of their contents.
+type GitIgnore struct {
+	patterns func(dir string) []gitPattern
+	excludes func(root string) []gitPattern
+	exists   func(path string) bool
+}
+
+func NewGitIgnore() *GitIgnore {
+	return &GitIgnore{
+		patterns: CachedFunc(readGitIgnore),
+		excludes: CachedFunc(readGitExclude),
+		exists:   CachedFunc(fileExists),
+	}
+}
+
+// Ignored returns true if the path found by the walk from root should be ignored.
+// The .git directory is always ignored.
+// Outside git repositories, the files above root are not read.
+func (g *GitIgnore) Ignored(root, path string, isDir bool) bool {
+	if isDir && filepath.Base(path) == gitDir {
+		return true
+	}
+
+	dirs, repo, err := gitRepoDirs(root, path, g.exists)
+	if err != nil {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	var ignored bool
+	apply := func(dir string, patterns []gitPattern) {
+		if len(patterns) == 0 {
+			return
+		}
+		rel, err := filepath.Rel(dir, abs)
+		if err != nil {
+			return
+		}
+		rel = filepath.ToSlash(rel)
+		// the last matching pattern decides
+		for _, p := range patterns {
+			if p.match(rel, isDir) {
+				ignored = !p.negate
+			}
+		}
+	}
+
+	if repo != "" {
+		apply(repo, g.excludes(repo))
+	}
+	// deeper files take precedence
+	for i := len(dirs) - 1; i >= 0; i-- {
+		apply(dirs[i].abs, g.patterns(dirs[i].abs))
+	}
+	if ignored {
+		AddMetricCount("gitignore-skip", 1)
+	}
+	return ignored
+}
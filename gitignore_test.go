@@ -0,0 +1,130 @@
+package grdep_test
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/grdep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitIgnore(t *testing.T) {
+	root := t.TempDir()
+	for p, content := range map[string]string{
+		".git/HEAD":         "",
+		".git/info/exclude": "*.tmp\n",
+		".gitignore": `# comment
+node_modules/
+/build
+*.log
+!keep.log
+\#hash
+docs/**/*.html
+`,
+		"a.sh":                  "",
+		"x.tmp":                 "",
+		"debug.log":             "",
+		"keep.log":              "",
+		"#hash":                 "",
+		"node_modules/m/i.js":   "",
+		"src/node_modules":      "", // not a directory
+		"src/build/b.sh":        "",
+		"build/out.sh":          "",
+		"docs/a/b/index.html":   "",
+		"docs/index.md":         "",
+		"sub/.gitignore":        "!*.log\nlocal.sh\n",
+		"sub/.ignore":           "!local.sh\nrg.sh\n",
+		"sub/sub.log":           "",
+		"sub/local.sh":          "",
+		"sub/rg.sh":             "",
+		"sub/deep/inner.sh":     "",
+		"sub/deep/.gitignore":   "*.sh\n",
+		"sub/deep/x.txt":        "",
+		"outer/.gitignore/file": "", // a directory named .gitignore is not read
+	} {
+		p = filepath.Join(root, p)
+		if !assert.Nil(t, os.MkdirAll(filepath.Dir(p), 0755)) {
+			return
+		}
+		if !assert.Nil(t, os.WriteFile(p, []byte(content), 0644)) {
+			return
+		}
+	}
+
+	ignore := grdep.NewGitIgnore()
+	walker := grdep.NewWalker(root, grdep.MatcherSet(nil), grdep.WithSkip(func(path string, info fs.FileInfo) bool {
+		return ignore.Ignored(root, path, info.IsDir())
+	}))
+
+	got := []string{}
+	for f := range walker.Walk(context.TODO()) {
+		rel, err := filepath.Rel(root, f.Path)
+		if !assert.Nil(t, err) {
+			return
+		}
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+
+	assert.Equal(t, []string{
+		".gitignore",
+		"a.sh",
+		"docs/index.md",
+		"keep.log",
+		"outer/.gitignore/file",
+		"src/build/b.sh",
+		"src/node_modules",
+		"sub/.gitignore",
+		"sub/.ignore",
+		"sub/deep/.gitignore",
+		"sub/deep/x.txt",
+		"sub/local.sh",
+		"sub/sub.log",
+	}, got)
+}
+
+func TestGitIgnoreOutsideRepository(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	for p, content := range map[string]string{
+		".gitignore":      "*.sh\n", // above the root of the walk, not read
+		"root/.gitignore": strings.Repeat("x", 70000) + "\n*.log\n",
+		"root/a.sh":       "",
+		"root/b.log":      "",
+		"root/c.txt":      "",
+	} {
+		p = filepath.Join(parent, p)
+		if !assert.Nil(t, os.MkdirAll(filepath.Dir(p), 0755)) {
+			return
+		}
+		if !assert.Nil(t, os.WriteFile(p, []byte(content), 0644)) {
+			return
+		}
+	}
+
+	ignore := grdep.NewGitIgnore()
+	walker := grdep.NewWalker(root, grdep.MatcherSet(nil), grdep.WithSkip(func(path string, info fs.FileInfo) bool {
+		return ignore.Ignored(root, path, info.IsDir())
+	}))
+
+	got := []string{}
+	for f := range walker.Walk(context.TODO()) {
+		rel, err := filepath.Rel(root, f.Path)
+		if !assert.Nil(t, err) {
+			return
+		}
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+
+	assert.Equal(t, []string{
+		".gitignore",
+		"a.sh",
+		"c.txt",
+	}, got)
+}
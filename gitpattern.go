@@ -0,0 +1,100 @@
+package grdep
+
+import (
+	"path"
+	"strings"
+)
+
+// gitPattern is a pattern of .gitignore and .gitattributes.
+// See https://git-scm.com/docs/gitignore#_pattern_format
+type gitPattern struct {
+	segments []string
+	negate   bool
+	// dirOnly matches only directories, the pattern ends with a slash.
+	dirOnly bool
+	// basename matches the name at any depth, the pattern has no slash.
+	basename bool
+}
+
+// parseGitPattern returns false if the line is blank or a comment.
+func parseGitPattern(line string) (gitPattern, bool) {
+	line = trimGitPatternSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return gitPattern{}, false
+	}
+
+	var p gitPattern
+	switch {
+	case strings.HasPrefix(line, "!"):
+		p.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return gitPattern{}, false
+	}
+	if !strings.Contains(line, "/") {
+		p.basename = true
+	}
+	line = strings.TrimPrefix(line, "/")
+
+	for _, x := range strings.Split(line, "/") {
+		// [!...] in git is [^...] in path.Match
+		p.segments = append(p.segments, strings.ReplaceAll(x, "[!", "[^"))
+	}
+	return p, true
+}
+
+// trimGitPatternSpace removes trailing spaces unless they are escaped.
+func trimGitPatternSpace(line string) string {
+	line = strings.TrimSuffix(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// match returns true if the path relative to the directory of the pattern file matches.
+// The path is separated by slashes.
+func (p gitPattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	names := strings.Split(rel, "/")
+	if p.basename {
+		return matchGitSegment(p.segments[0], names[len(names)-1])
+	}
+	return matchGitSegments(p.segments, names)
+}
+
+func matchGitSegments(patterns, names []string) bool {
+	if len(patterns) == 0 {
+		return len(names) == 0
+	}
+	if patterns[0] == "**" {
+		if len(patterns) == 1 {
+			// trailing /** matches everything inside
+			return len(names) > 0
+		}
+		for i := 0; i <= len(names); i++ {
+			if matchGitSegments(patterns[1:], names[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(names) == 0 || !matchGitSegment(patterns[0], names[0]) {
+		return false
+	}
+	return matchGitSegments(patterns[1:], names[1:])
+}
+
+func matchGitSegment(pattern, name string) bool {
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
@@ -0,0 +1,78 @@
+package grdep
+
+import "fmt"
+
+// CategoryHierarchy declares is-a relationships between categories.
+type CategoryHierarchy []CategoryParents
+
+// CategoryParents declares that the category is a kind of the parents.
+type CategoryParents struct {
+	Category string   `yaml:"category" json:"category"`
+	Parents  []string `yaml:"parents" json:"parents"`
+}
+
+func (h CategoryHierarchy) parents() map[string][]string {
+	r := map[string][]string{}
+	for _, x := range h {
+		r[x.Category] = append(r[x.Category], x.Parents...)
+	}
+	return r
+}
+
+// Ancestors returns the ancestors of the category in breadth-first order without duplicates.
+func (h CategoryHierarchy) Ancestors(category string) []string {
+	var (
+		parents = h.parents()
+		visited = map[string]bool{category: true}
+		queue   = []string{category}
+		result  = []string{}
+	)
+	for len(queue) > 0 {
+		x := queue[0]
+		queue = queue[1:]
+		for _, p := range parents[x] {
+			if visited[p] {
+				continue
+			}
+			visited[p] = true
+			result = append(result, p)
+			queue = append(queue, p)
+		}
+	}
+	return result
+}
+
+func (h CategoryHierarchy) Validate() error {
+	for i, x := range h {
+		if x.Category == "" || len(x.Parents) == 0 {
+			return fmt.Errorf("%w: hierarchy[%d] requires category and parents", ErrInvalidConfig, i)
+		}
+	}
+
+	parents := h.parents()
+	// 0: not visited, 1: visiting, 2: visited
+	state := map[string]int{}
+	var visit func(category string) error
+	visit = func(category string) error {
+		switch state[category] {
+		case 1:
+			return fmt.Errorf("%w: hierarchy has a cycle at %s", ErrInvalidConfig, category)
+		case 2:
+			return nil
+		}
+		state[category] = 1
+		for _, p := range parents[category] {
+			if err := visit(p); err != nil {
+				return err
+			}
+		}
+		state[category] = 2
+		return nil
+	}
+	for _, x := range h {
+		if err := visit(x.Category); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package grdep_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/grdep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryHierarchy(t *testing.T) {
+	t.Run("Ancestors", func(t *testing.T) {
+		h := grdep.CategoryHierarchy{
+			{Category: "bash", Parents: []string{"sh"}},
+			{Category: "sh", Parents: []string{"shell"}},
+			{Category: "zsh", Parents: []string{"shell"}},
+			{Category: "yaml/k8s", Parents: []string{"yaml", "k8s"}},
+			{Category: "k8s", Parents: []string{"yaml"}},
+		}
+		for _, tc := range []struct {
+			category string
+			want     []string
+		}{
+			{category: "shell", want: []string{}},
+			{category: "bash", want: []string{"sh", "shell"}},
+			{category: "zsh", want: []string{"shell"}},
+			{category: "yaml/k8s", want: []string{"yaml", "k8s"}},
+			{category: "unknown", want: []string{}},
+		} {
+			t.Run(tc.category, func(t *testing.T) {
+				assert.Equal(t, tc.want, h.Ancestors(tc.category))
+			})
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		for _, tc := range []struct {
+			name string
+			h    grdep.CategoryHierarchy
+			err  bool
+		}{
+			{
+				name: "empty",
+			},
+			{
+				name: "diamond",
+				h: grdep.CategoryHierarchy{
+					{Category: "a", Parents: []string{"b", "c"}},
+					{Category: "b", Parents: []string{"d"}},
+					{Category: "c", Parents: []string{"d"}},
+				},
+			},
+			{
+				name: "no parents",
+				h: grdep.CategoryHierarchy{
+					{Category: "a"},
+				},
+				err: true,
+			},
+			{
+				name: "cycle",
+				h: grdep.CategoryHierarchy{
+					{Category: "a", Parents: []string{"b"}},
+					{Category: "b", Parents: []string{"c"}},
+					{Category: "c", Parents: []string{"a"}},
+				},
+				err: true,
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				assert.Equal(t, tc.err, tc.h.Validate() != nil)
+			})
+		}
+	})
+}
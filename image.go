@@ -0,0 +1,436 @@
+package grdep
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var ErrImage = errors.New("Image")
+
+var (
+	_ WalkerIface = &ImageWalker{}
+)
+
+// NewImageWalker returns a walker of the filesystem of a container image,
+// root is a docker save tarball or an OCI image layout directory.
+//
+// The layers are applied in order with whiteouts, and the files of the merged filesystem are yielded
+// as paths like image.tar!/usr/bin/app with the digest of the layer that provides the file.
+// Hard links are not yielded.
+func NewImageWalker(root string, ignores MatcherIface, opt ...WalkerOption) *ImageWalker {
+	return &ImageWalker{
+		walker: NewWalker(root, ignores, opt...),
+	}
+}
+
+type ImageWalker struct {
+	walker *Walker
+}
+
+func (w ImageWalker) Walk(ctx context.Context) <-chan *File {
+	resultC := make(chan *File, 100)
+
+	go func() {
+		defer close(resultC)
+
+		root := w.walker.root
+		if err := w.walk(ctx, resultC); err != nil {
+			resultC <- newErrFile(root, fmt.Errorf("%w: %w: %s", ErrImage, err, root))
+		}
+	}()
+
+	return resultC
+}
+
+func (w ImageWalker) walk(ctx context.Context, resultC chan<- *File) error {
+	src, err := openImageSource(w.walker.root)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	layers, err := readImageLayers(src)
+	if err != nil {
+		return err
+	}
+	owners, err := mergeImageLayers(layers)
+	if err != nil {
+		return err
+	}
+
+	for i, layer := range layers {
+		if err := w.walkLayer(ctx, resultC, layer, i, owners); err != nil {
+			return fmt.Errorf("%w: layer %s", err, layer.digest)
+		}
+	}
+	return nil
+}
+
+// walkLayer yields the files owned by the layer.
+func (w ImageWalker) walkLayer(ctx context.Context, resultC chan<- *File, layer *imageLayer, index int, owners map[string]int) error {
+	rc, err := layer.open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	tr, err := newLayerReader(rc)
+	if err != nil {
+		return err
+	}
+
+	for {
+		if IsDone(ctx) {
+			return ctx.Err()
+		}
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := cleanArchiveName(header.Name)
+		if owner, ok := owners[name]; !ok || owner != index || header.Typeflag != tar.TypeReg {
+			continue
+		}
+		// yield the first one if the layer has the same names
+		delete(owners, name)
+
+		var (
+			filePath = w.walker.root + ArchiveSeparator + name
+			info     = header.FileInfo()
+		)
+		if w.walker.isSkip(filePath, info) {
+			continue
+		}
+		content, err := readArchiveEntry(tr, header.Size, w.walker.archiveMaxEntrySize)
+		if errors.Is(err, ErrArchiveEntryTooLarge) {
+			resultC <- newErrFile(filePath, fmt.Errorf("%w: %w: %s", ErrImage, err, filePath))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		f := NewFile(filePath, info, func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(content)), nil
+		})
+		f.Layer = layer.digest
+		w.walker.send(ctx, resultC, f, w.walker.archiveDepth)
+	}
+}
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// mergeImageLayers applies the layers in order and returns the index of the layer
+// that provides each regular file of the merged filesystem.
+// The digests of the layers are calculated if unknown.
+func mergeImageLayers(layers []*imageLayer) (map[string]int, error) {
+	owners := map[string]int{}
+	// deleteLower deletes the files of the lower layers under the prefix
+	deleteLower := func(prefix string, index int) {
+		for name, owner := range owners {
+			if owner < index && strings.HasPrefix(name, prefix) {
+				delete(owners, name)
+			}
+		}
+	}
+
+	for i, layer := range layers {
+		if err := func() error {
+			rc, err := layer.open()
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+
+			var r io.Reader = rc
+			hash := sha256.New()
+			if layer.digest == "" {
+				r = io.TeeReader(rc, hash)
+			}
+			tr, err := newLayerReader(r)
+			if err != nil {
+				return err
+			}
+			for {
+				header, err := tr.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				var (
+					name      = cleanArchiveName(header.Name)
+					dir, base = path.Split(name)
+				)
+				switch {
+				case base == whiteoutOpaque:
+					deleteLower(dir, i)
+				case strings.HasPrefix(base, whiteoutPrefix):
+					target := dir + strings.TrimPrefix(base, whiteoutPrefix)
+					if owner, ok := owners[target]; ok && owner < i {
+						delete(owners, target)
+					}
+					deleteLower(target+"/", i)
+				case header.Typeflag == tar.TypeReg:
+					owners[name] = i
+					deleteLower(name+"/", i)
+				default:
+					// directories, symlinks and links replace the files of the lower layers
+					delete(owners, name)
+					if header.Typeflag != tar.TypeDir {
+						// and the directories of the lower layers with their files
+						deleteLower(name+"/", i)
+					}
+				}
+			}
+			if layer.digest == "" {
+				// read the rest like padding
+				if _, err := io.Copy(io.Discard, r); err != nil {
+					return err
+				}
+				layer.digest = "sha256:" + hex.EncodeToString(hash.Sum(nil))
+			}
+			return nil
+		}(); err != nil {
+			return nil, fmt.Errorf("%w: layer[%d]", err, i)
+		}
+	}
+	return owners, nil
+}
+
+var magicZstd = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// newLayerReader returns a reader of the layer, a tar optionally compressed by gzip.
+func newLayerReader(r io.Reader) (*tar.Reader, error) {
+	br := bufio.NewReader(r)
+	b, _ := br.Peek(4)
+	switch {
+	case bytes.HasPrefix(b, magicGzip):
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return tar.NewReader(gr), nil
+	case bytes.HasPrefix(b, magicZstd):
+		return nil, fmt.Errorf("%w: zstd layer is not supported", ErrImage)
+	default:
+		return tar.NewReader(br), nil
+	}
+}
+
+// imageLayer is a layer of an image.
+type imageLayer struct {
+	// digest is like sha256:hex.
+	digest string
+	open   func() (io.ReadCloser, error)
+}
+
+// readImageLayers reads the layers of the first image from manifest.json of docker save,
+// or from index.json of OCI image layout.
+func readImageLayers(src imageSource) ([]*imageLayer, error) {
+	if b, err := readImageFile(src, "manifest.json"); err == nil {
+		return readDockerManifest(src, b)
+	}
+	b, err := readImageFile(src, "index.json")
+	if err != nil {
+		return nil, fmt.Errorf("%w: neither manifest.json nor index.json found", ErrImage)
+	}
+	return readOCIIndex(src, b)
+}
+
+func readImageFile(src imageSource, name string) ([]byte, error) {
+	rc, err := src.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+type dockerManifest struct {
+	Layers []string `json:"Layers"`
+}
+
+func readDockerManifest(src imageSource, b []byte) ([]*imageLayer, error) {
+	var manifests []dockerManifest
+	if err := json.Unmarshal(b, &manifests); err != nil {
+		return nil, fmt.Errorf("%w: manifest.json", err)
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("%w: no images in manifest.json", ErrImage)
+	}
+
+	layers := make([]*imageLayer, len(manifests[0].Layers))
+	for i, name := range manifests[0].Layers {
+		layers[i] = &imageLayer{
+			// blobs/sha256/hex since docker 25, calculated later for layer.tar
+			digest: blobDigest(name),
+			open: func() (io.ReadCloser, error) {
+				return src.Open(name)
+			},
+		}
+	}
+	return layers, nil
+}
+
+// blobDigest returns the digest of blobs/alg/hex, empty if the name is not a blob.
+func blobDigest(name string) string {
+	xs := strings.Split(name, "/")
+	if len(xs) != 3 || xs[0] != "blobs" {
+		return ""
+	}
+	return xs[1] + ":" + xs[2]
+}
+
+func blobName(digest string) string {
+	return path.Join("blobs", strings.Replace(digest, ":", "/", 1))
+}
+
+const (
+	mediaTypeOCIIndex    = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerIndex = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+type ociIndex struct {
+	Manifests []ociDescriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	Layers []ociDescriptor `json:"layers"`
+}
+
+func readOCIIndex(src imageSource, b []byte) ([]*imageLayer, error) {
+	var index ociIndex
+	if err := json.Unmarshal(b, &index); err != nil {
+		return nil, fmt.Errorf("%w: index", err)
+	}
+	if len(index.Manifests) == 0 {
+		return nil, fmt.Errorf("%w: no manifests in index", ErrImage)
+	}
+
+	desc := index.Manifests[0]
+	b, err := readImageFile(src, blobName(desc.Digest))
+	if err != nil {
+		return nil, fmt.Errorf("%w: manifest %s", err, desc.Digest)
+	}
+	switch desc.MediaType {
+	case mediaTypeOCIIndex, mediaTypeDockerIndex:
+		// multi-platform image, use the first one
+		return readOCIIndex(src, b)
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: manifest %s", err, desc.Digest)
+	}
+	layers := make([]*imageLayer, len(manifest.Layers))
+	for i, x := range manifest.Layers {
+		layers[i] = &imageLayer{
+			digest: x.Digest,
+			open: func() (io.ReadCloser, error) {
+				return src.Open(blobName(x.Digest))
+			},
+		}
+	}
+	return layers, nil
+}
+
+// imageSource opens files of an image by the names separated by slashes.
+type imageSource interface {
+	Open(name string) (io.ReadCloser, error)
+	Close() error
+}
+
+func openImageSource(root string) (imageSource, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return dirImageSource(root), nil
+	}
+	return newTarImageSource(root)
+}
+
+// dirImageSource is an OCI image layout directory or an extracted docker save tarball.
+type dirImageSource string
+
+func (s dirImageSource) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(s), filepath.FromSlash(name)))
+}
+
+func (dirImageSource) Close() error { return nil }
+
+// tarImageSource is a docker save tarball, the entries are read by offsets.
+type tarImageSource struct {
+	f       *os.File
+	entries map[string]*io.SectionReader
+}
+
+func newTarImageSource(root string) (*tarImageSource, error) {
+	f, err := os.Open(root)
+	if err != nil {
+		return nil, err
+	}
+	s := &tarImageSource{
+		f:       f,
+		entries: map[string]*io.SectionReader{},
+	}
+
+	// tar.Reader skips the content by Seek
+	tr := tar.NewReader(f)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return s, nil
+		}
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		offset, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		s.entries[cleanArchiveName(header.Name)] = io.NewSectionReader(f, offset, header.Size)
+	}
+}
+
+func (s *tarImageSource) Open(name string) (io.ReadCloser, error) {
+	r, ok := s.entries[cleanArchiveName(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", os.ErrNotExist, name)
+	}
+	return io.NopCloser(io.NewSectionReader(r, 0, r.Size())), nil
+}
+
+func (s *tarImageSource) Close() error {
+	return s.f.Close()
+}
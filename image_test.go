@@ -0,0 +1,205 @@
+package grdep_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/grdep"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTarEntry struct {
+	name     string
+	content  string
+	typeflag byte
+}
+
+func newTestTar(t *testing.T, entries []testTarEntry, compress bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var (
+		gw = gzip.NewWriter(&buf)
+		w  *tar.Writer
+	)
+	if compress {
+		w = tar.NewWriter(gw)
+	} else {
+		w = tar.NewWriter(&buf)
+	}
+	for _, x := range entries {
+		typeflag := x.typeflag
+		if typeflag == 0 {
+			typeflag = tar.TypeReg
+		}
+		header := &tar.Header{
+			Typeflag: typeflag,
+			Name:     x.name,
+			Mode:     0644,
+		}
+		if typeflag == tar.TypeReg {
+			header.Size = int64(len(x.content))
+		}
+		if typeflag == tar.TypeSymlink {
+			header.Linkname = x.content
+		}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if typeflag == tar.TypeReg {
+			if _, err := w.Write([]byte(x.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if compress {
+		if err := gw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func testDigest(b []byte) string {
+	h := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(h[:])
+}
+
+func TestImageWalker(t *testing.T) {
+	layers := [][]testTarEntry{
+		{
+			{name: "etc/", typeflag: tar.TypeDir},
+			{name: "etc/app.conf", content: "v1"},
+			{name: "etc/old.conf", content: "old"},
+			{name: "usr/bin/tool", content: "tool"},
+			{name: "usr/bin/link", content: "tool"},
+			{name: "opt/app/a.sh", content: "a"},
+			{name: "opt/app/b.sh", content: "b"},
+			{name: "var/log/x.log", content: "log"},
+			{name: "srv/data/d.sh", content: "d"},
+		},
+		{
+			{name: "etc/app.conf", content: "v2"},
+			{name: "etc/.wh.old.conf"},
+			{name: "usr/bin/link", content: "tool", typeflag: tar.TypeSymlink},
+			{name: "opt/app/.wh..wh..opq"},
+			{name: "opt/app/c.sh", content: "c"},
+			{name: "var/.wh.log"},
+			{name: "usr/bin/", typeflag: tar.TypeDir},                      // keeps the files of the lower layers
+			{name: "srv/data", content: "/tmp", typeflag: tar.TypeSymlink}, // replaces the directory of the lower layers
+		},
+	}
+	want := func(digests []string) [][3]string {
+		return [][3]string{
+			{"etc/app.conf", "v2", digests[1]},
+			{"opt/app/c.sh", "c", digests[1]},
+			{"usr/bin/tool", "tool", digests[0]},
+		}
+	}
+
+	walk := func(t *testing.T, root string) [][3]string {
+		got := [][3]string{}
+		for f := range grdep.NewImageWalker(root, grdep.MatcherSet(nil)).Walk(context.TODO()) {
+			var content []string
+			for x := range f.Lines(context.TODO()) {
+				if !assert.Nil(t, x.Err) {
+					continue
+				}
+				content = append(content, x.Content)
+			}
+			name, ok := strings.CutPrefix(f.Path, root+grdep.ArchiveSeparator)
+			assert.True(t, ok)
+			got = append(got, [3]string{name, strings.Join(content, "\n"), f.Layer})
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i][0] < got[j][0] })
+		return got
+	}
+
+	t.Run("docker save", func(t *testing.T) {
+		var (
+			blobs   = make([][]byte, len(layers))
+			digests = make([]string, len(layers))
+			entries = []testTarEntry{}
+			names   = []string{}
+		)
+		for i, x := range layers {
+			blobs[i] = newTestTar(t, x, false)
+			digests[i] = testDigest(blobs[i])
+			name := filepath.ToSlash(filepath.Join(string(rune('a'+i)), "layer.tar"))
+			names = append(names, name)
+			entries = append(entries, testTarEntry{name: name, content: string(blobs[i])})
+		}
+		manifest, _ := json.Marshal([]map[string]any{{"Layers": names}})
+		entries = append(entries, testTarEntry{name: "manifest.json", content: string(manifest)})
+		root := filepath.Join(t.TempDir(), "image.tar")
+		if !assert.Nil(t, os.WriteFile(root, newTestTar(t, entries, false), 0644)) {
+			return
+		}
+		assert.Equal(t, want(digests), walk(t, root))
+	})
+
+	t.Run("oci layout", func(t *testing.T) {
+		root := t.TempDir()
+		writeBlob := func(b []byte) string {
+			digest := testDigest(b)
+			p := filepath.Join(root, "blobs", "sha256", strings.TrimPrefix(digest, "sha256:"))
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(p, b, 0644); err != nil {
+				t.Fatal(err)
+			}
+			return digest
+		}
+
+		var (
+			digests     = make([]string, len(layers))
+			descriptors = []map[string]string{}
+		)
+		for i, x := range layers {
+			digests[i] = writeBlob(newTestTar(t, x, true))
+			descriptors = append(descriptors, map[string]string{
+				"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip",
+				"digest":    digests[i],
+			})
+		}
+		manifest, _ := json.Marshal(map[string]any{"layers": descriptors})
+		index, _ := json.Marshal(map[string]any{"manifests": []map[string]string{{
+			"mediaType": "application/vnd.oci.image.manifest.v1+json",
+			"digest":    writeBlob(manifest),
+		}}})
+		nested, _ := json.Marshal(map[string]any{"manifests": []map[string]string{{
+			"mediaType": "application/vnd.oci.image.index.v1+json",
+			"digest":    writeBlob(index),
+		}}})
+		if !assert.Nil(t, os.WriteFile(filepath.Join(root, "index.json"), nested, 0644)) {
+			return
+		}
+		assert.Equal(t, want(digests), walk(t, root))
+	})
+
+	t.Run("not an image", func(t *testing.T) {
+		root := t.TempDir()
+		var errs []error
+		for f := range grdep.NewImageWalker(root, grdep.MatcherSet(nil)).Walk(context.TODO()) {
+			for x := range f.Lines(context.TODO()) {
+				errs = append(errs, x.Err)
+			}
+		}
+		if assert.Equal(t, 1, len(errs)) {
+			assert.ErrorIs(t, errs[0], grdep.ErrImage)
+		}
+	})
+}
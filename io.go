@@ -4,14 +4,18 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 )
 
 type ReadLinesResult struct {
 	Linum int    `json:"linum"`
 	Text  string `json:"text"`
 	Err   error  `json:"err,omitempty"`
+	// Truncated is true if the line is longer than the max line length.
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 func (r ReadLinesResult) String() string {
@@ -21,14 +25,48 @@ func (r ReadLinesResult) String() string {
 func ReadLines(ctx context.Context, r io.Reader) <-chan ReadLinesResult {
 	resultC := make(chan ReadLinesResult, 1000)
 
+	go func() {
+		defer close(resultC)
+
+		reader := NewLineReader(r)
+		for {
+			if IsDone(ctx) {
+				resultC <- ReadLinesResult{
+					Err: ctx.Err(),
+				}
+				return
+			}
+
+			x, err := reader.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				resultC <- ReadLinesResult{
+					Err: err,
+				}
+				return
+			}
+			resultC <- x
+		}
+	}()
+
+	return resultC
+}
+
+// ReadNullSeparated reads the records separated by NUL like the output of find -print0.
+// Empty records are skipped.
+func ReadNullSeparated(ctx context.Context, r io.Reader) <-chan ReadLinesResult {
+	resultC := make(chan ReadLinesResult, 1000)
+
 	go func() {
 		defer close(resultC)
 
 		var (
-			scanner = bufio.NewScanner(r)
-			linum   int
+			reader = bufio.NewReader(r)
+			linum  int
 		)
-		for scanner.Scan() {
+		for {
 			if IsDone(ctx) {
 				resultC <- ReadLinesResult{
 					Err: ctx.Err(),
@@ -36,15 +74,22 @@ func ReadLines(ctx context.Context, r io.Reader) <-chan ReadLinesResult {
 				return
 			}
 
+			text, err := reader.ReadString(0)
+			if err != nil && !errors.Is(err, io.EOF) {
+				resultC <- ReadLinesResult{
+					Err: err,
+				}
+				return
+			}
 			linum++
-			resultC <- ReadLinesResult{
-				Linum: linum,
-				Text:  scanner.Text(),
+			if text = strings.TrimSuffix(text, "\x00"); text != "" {
+				resultC <- ReadLinesResult{
+					Linum: linum,
+					Text:  text,
+				}
 			}
-		}
-		if err := scanner.Err(); err != nil {
-			resultC <- ReadLinesResult{
-				Err: err,
+			if err != nil {
+				return
 			}
 		}
 	}()
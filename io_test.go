@@ -61,3 +61,16 @@ line2`,
 		})
 	}
 }
+
+func TestReadNullSeparated(t *testing.T) {
+	got := []grdep.ReadLinesResult{}
+	for x := range grdep.ReadNullSeparated(context.TODO(), bytes.NewBufferString("a\x00b\nc\x00\x00d")) {
+		assert.Nil(t, x.Err)
+		got = append(got, x)
+	}
+	assert.Equal(t, []grdep.ReadLinesResult{
+		{Linum: 1, Text: "a"},
+		{Linum: 2, Text: "b\nc"},
+		{Linum: 4, Text: "d"},
+	}, got)
+}
@@ -0,0 +1,239 @@
+package grdep
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	// LongLineTruncate truncates lines longer than the max line length.
+	LongLineTruncate = "truncate"
+	// LongLineSkip skips lines longer than the max line length.
+	LongLineSkip = "skip"
+
+	// DefaultMaxLineLength is the default max line length in bytes.
+	DefaultMaxLineLength = 1024 * 1024
+)
+
+var ErrInvalidLongLine = errors.New("InvalidLongLine")
+
+// ValidateLongLine returns an error if the policy is unknown, empty is truncate.
+func ValidateLongLine(policy string) error {
+	switch policy {
+	case "", LongLineTruncate, LongLineSkip:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidLongLine, policy)
+	}
+}
+
+type LineReaderOption func(*LineReader)
+
+// WithMaxLineLength sets the max line length in bytes, 0 or less means DefaultMaxLineLength.
+func WithMaxLineLength(n int) LineReaderOption {
+	return func(r *LineReader) {
+		if n > 0 {
+			r.maxLength = n
+		}
+	}
+}
+
+// WithLongLine sets the policy for lines longer than the max line length, truncate or skip.
+func WithLongLine(policy string) LineReaderOption {
+	return func(r *LineReader) {
+		if policy != "" {
+			r.longLine = policy
+		}
+	}
+}
+
+// NewLineReader returns a reader of the lines of r.
+//
+// Lines are separated by LF or CRLF.
+// The content starting with BOM is decoded from UTF-16 and the BOM of UTF-8 is removed.
+func NewLineReader(r io.Reader, opt ...LineReaderOption) *LineReader {
+	return newLineReader(decodeBOM(r), opt...)
+}
+
+// newLineReader does not decode r.
+func newLineReader(r io.Reader, opt ...LineReaderOption) *LineReader {
+	x := &LineReader{
+		r:         bufio.NewReader(r),
+		maxLength: DefaultMaxLineLength,
+		longLine:  LongLineTruncate,
+	}
+	for _, f := range opt {
+		f(x)
+	}
+	return x
+}
+
+// LineReader reads lines without the limit of bufio.Scanner.
+type LineReader struct {
+	r         *bufio.Reader
+	maxLength int
+	longLine  string
+	linum     int
+}
+
+// Next returns the next line, io.EOF if there are no more lines.
+func (r *LineReader) Next() (ReadLinesResult, error) {
+	for {
+		line, truncated, err := r.readLine()
+		if err != nil {
+			return ReadLinesResult{}, err
+		}
+		r.linum++
+		if truncated {
+			AddMetricCount("long-line", 1)
+			if r.longLine == LongLineSkip {
+				continue
+			}
+		}
+		return ReadLinesResult{
+			Linum:     r.linum,
+			Text:      line,
+			Truncated: truncated,
+		}, nil
+	}
+}
+
+// readLine reads a line up to the max length and discards the rest.
+func (r *LineReader) readLine() (string, bool, error) {
+	var (
+		buf       []byte
+		truncated bool
+		read      bool
+	)
+	for {
+		chunk, isPrefix, err := r.r.ReadLine()
+		if errors.Is(err, io.EOF) && read {
+			// the last line without LF, ReadLine does not return this
+			return string(buf), truncated, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		read = true
+		if rest := r.maxLength - len(buf); rest > 0 {
+			if len(chunk) > rest {
+				chunk = trimIncompleteRune(chunk[:rest])
+				truncated = true
+			}
+			buf = append(buf, chunk...)
+		} else if len(chunk) > 0 {
+			truncated = true
+		}
+		if !isPrefix {
+			return string(buf), truncated, nil
+		}
+	}
+}
+
+// trimIncompleteRune removes a rune cut off at the end.
+func trimIncompleteRune(b []byte) []byte {
+	for i := 0; i < utf8.UTFMax && i < len(b); i++ {
+		if utf8.RuneStart(b[len(b)-1-i]) {
+			if !utf8.FullRune(b[len(b)-1-i:]) {
+				return b[:len(b)-1-i]
+			}
+			return b
+		}
+	}
+	return b
+}
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16BE = []byte{0xfe, 0xff}
+	bomUTF16LE = []byte{0xff, 0xfe}
+)
+
+// decodeBOM returns the reader that decodes r by BOM.
+func decodeBOM(r io.Reader) io.Reader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	b, _ := br.Peek(3)
+	switch {
+	case bytes.HasPrefix(b, bomUTF8):
+		_, _ = br.Discard(len(bomUTF8))
+		return br
+	case bytes.HasPrefix(b, bomUTF16LE):
+		_, _ = br.Discard(len(bomUTF16LE))
+		return &utf16Reader{r: br, little: true}
+	case bytes.HasPrefix(b, bomUTF16BE):
+		_, _ = br.Discard(len(bomUTF16BE))
+		return &utf16Reader{r: br}
+	default:
+		return br
+	}
+}
+
+// utf16Reader decodes UTF-16 into UTF-8.
+type utf16Reader struct {
+	r      *bufio.Reader
+	little bool
+	buf    []byte // decoded but not read
+	err    error
+}
+
+func (r *utf16Reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.fill()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// fill decodes the next rune.
+func (r *utf16Reader) fill() {
+	u, err := r.next()
+	if err != nil {
+		r.err = err
+		return
+	}
+	if utf16.IsSurrogate(rune(u)) {
+		u2, err := r.next()
+		if err != nil {
+			r.buf = utf8.AppendRune(r.buf, utf8.RuneError)
+			r.err = err
+			return
+		}
+		if x := utf16.DecodeRune(rune(u), rune(u2)); x != utf8.RuneError {
+			r.buf = utf8.AppendRune(r.buf, x)
+			return
+		}
+		// unpaired surrogate
+		r.buf = utf8.AppendRune(r.buf, utf8.RuneError)
+		if !utf16.IsSurrogate(rune(u2)) {
+			r.buf = utf8.AppendRune(r.buf, rune(u2))
+		}
+		return
+	}
+	r.buf = utf8.AppendRune(r.buf, rune(u))
+}
+
+func (r *utf16Reader) next() (uint16, error) {
+	var b [2]byte
+	if _, err := io.ReadFull(r.r, b[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, io.EOF
+		}
+		return 0, err
+	}
+	if r.little {
+		return uint16(b[0]) | uint16(b[1])<<8, nil
+	}
+	return uint16(b[0])<<8 | uint16(b[1]), nil
+}
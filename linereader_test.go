@@ -0,0 +1,137 @@
+package grdep_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/berquerant/grdep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineReader(t *testing.T) {
+	encodeUTF16 := func(s string, little bool) string {
+		var b bytes.Buffer
+		if little {
+			b.Write([]byte{0xff, 0xfe})
+		} else {
+			b.Write([]byte{0xfe, 0xff})
+		}
+		for _, u := range utf16.Encode([]rune(s)) {
+			if little {
+				b.Write([]byte{byte(u), byte(u >> 8)})
+			} else {
+				b.Write([]byte{byte(u >> 8), byte(u)})
+			}
+		}
+		return b.String()
+	}
+
+	for _, tc := range []struct {
+		name    string
+		content string
+		opt     []grdep.LineReaderOption
+		want    []grdep.ReadLinesResult
+	}{
+		{
+			name: "empty",
+			want: []grdep.ReadLinesResult{},
+		},
+		{
+			name:    "crlf",
+			content: "a\r\nb\r\n\r\nc",
+			want: []grdep.ReadLinesResult{
+				{Linum: 1, Text: "a"},
+				{Linum: 2, Text: "b"},
+				{Linum: 3, Text: ""},
+				{Linum: 4, Text: "c"},
+			},
+		},
+		{
+			name:    "utf-8 bom",
+			content: "\xef\xbb\xbfa\nb\n",
+			want: []grdep.ReadLinesResult{
+				{Linum: 1, Text: "a"},
+				{Linum: 2, Text: "b"},
+			},
+		},
+		{
+			name:    "utf-16le",
+			content: encodeUTF16("a\r\nあ😀\n", true),
+			want: []grdep.ReadLinesResult{
+				{Linum: 1, Text: "a"},
+				{Linum: 2, Text: "あ😀"},
+			},
+		},
+		{
+			name:    "utf-16be",
+			content: encodeUTF16("a\nb", false),
+			want: []grdep.ReadLinesResult{
+				{Linum: 1, Text: "a"},
+				{Linum: 2, Text: "b"},
+			},
+		},
+		{
+			name:    "truncate",
+			content: "abcdef\nab\n" + strings.Repeat("x", 10000) + "\nc",
+			opt: []grdep.LineReaderOption{
+				grdep.WithMaxLineLength(3),
+			},
+			want: []grdep.ReadLinesResult{
+				{Linum: 1, Text: "abc", Truncated: true},
+				{Linum: 2, Text: "ab"},
+				{Linum: 3, Text: "xxx", Truncated: true},
+				{Linum: 4, Text: "c"},
+			},
+		},
+		{
+			name:    "truncate does not cut runes",
+			content: "aあ",
+			opt: []grdep.LineReaderOption{
+				grdep.WithMaxLineLength(3),
+			},
+			want: []grdep.ReadLinesResult{
+				{Linum: 1, Text: "a", Truncated: true},
+			},
+		},
+		{
+			name:    "skip",
+			content: "abcdef\nab\n" + strings.Repeat("x", 10000) + "\nc",
+			opt: []grdep.LineReaderOption{
+				grdep.WithMaxLineLength(3),
+				grdep.WithLongLine(grdep.LongLineSkip),
+			},
+			want: []grdep.ReadLinesResult{
+				{Linum: 2, Text: "ab"},
+				{Linum: 4, Text: "c"},
+			},
+		},
+		{
+			name:    "longer than bufio.Scanner",
+			content: strings.Repeat("x", 100000) + "\ny",
+			want: []grdep.ReadLinesResult{
+				{Linum: 1, Text: strings.Repeat("x", 100000)},
+				{Linum: 2, Text: "y"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := grdep.NewLineReader(bytes.NewBufferString(tc.content), tc.opt...)
+			got := []grdep.ReadLinesResult{}
+			for {
+				x, err := r.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if !assert.Nil(t, err) {
+					return
+				}
+				got = append(got, x)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -1,47 +1,174 @@
 package grdep
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 
 	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
 )
 
+// LuaScript is a compiled lua script.
+// The script is compiled once and evaluated by a pool of states,
+// so Run can be called concurrently.
+//
+// Every state preloads the grdep module.
 type LuaScript struct {
 	entryPoint string // call function: string -> string
 
-	state *lua.LState
-	mux   sync.Mutex
+	proto *lua.FunctionProto
+	pool  *luaStatePool
+	opts  luaOptions
 }
 
-func NewLuaScriptFromFile(script, entryPoint string) (*LuaScript, error) {
-	state := lua.NewState()
-	if err := state.DoFile(script); err != nil {
+type luaOptions struct {
+	paths    []string
+	sandbox  bool
+	poolSize int
+}
+
+type LuaOption func(*luaOptions)
+
+// WithLuaPath adds directories to search modules by require.
+func WithLuaPath(dirs ...string) LuaOption {
+	return func(o *luaOptions) {
+		for _, dir := range dirs {
+			if dir != "" {
+				o.paths = append(o.paths, dir)
+			}
+		}
+	}
+}
+
+// WithLuaSandbox loads the script without os and io libraries, dofile and loadfile.
+// require searches modules only in the directories by WithLuaPath, package.path is ignored.
+func WithLuaSandbox() LuaOption {
+	return func(o *luaOptions) {
+		o.sandbox = true
+	}
+}
+
+// WithLuaPoolSize keeps at most size idle states, so states over the number of concurrent callers are closed.
+// Non-positive size means no limit.
+func WithLuaPoolSize(size int) LuaOption {
+	return func(o *luaOptions) {
+		o.poolSize = size
+	}
+}
+
+// NewLuaScriptFromFile compiles the script file.
+// require also searches modules in the directory of the script.
+func NewLuaScriptFromFile(script, entryPoint string, opt ...LuaOption) (*LuaScript, error) {
+	fp, err := os.Open(script)
+	if err != nil {
 		return nil, errors.Join(ErrLuaInvalidScript, err)
 	}
+	defer fp.Close()
 
-	return &LuaScript{
-		entryPoint: entryPoint,
-		state:      state,
-	}, nil
+	opt = append([]LuaOption{WithLuaPath(filepath.Dir(script))}, opt...)
+	return newLuaScript(fp, script, entryPoint, opt...)
 }
 
-func NewLuaScript(script, entryPoint string) (*LuaScript, error) {
-	state := lua.NewState()
-	if err := state.DoString(script); err != nil {
+// newLuaScriptFromFileContent compiles the content of the script file like NewLuaScriptFromFile.
+func newLuaScriptFromFileContent(script string, content []byte, entryPoint string, opt ...LuaOption) (*LuaScript, error) {
+	opt = append([]LuaOption{WithLuaPath(filepath.Dir(script))}, opt...)
+	return newLuaScript(bytes.NewReader(content), script, entryPoint, opt...)
+}
+
+func NewLuaScript(script, entryPoint string, opt ...LuaOption) (*LuaScript, error) {
+	return newLuaScript(bytes.NewBufferString(script), "<string>", entryPoint, opt...)
+}
+
+func newLuaScript(r io.Reader, name, entryPoint string, opt ...LuaOption) (*LuaScript, error) {
+	proto, err := compileLua(r, name)
+	if err != nil {
 		return nil, errors.Join(ErrLuaInvalidScript, err)
 	}
 
-	return &LuaScript{
+	s := &LuaScript{
 		entryPoint: entryPoint,
-		state:      state,
-	}, nil
+		proto:      proto,
+	}
+	for _, f := range opt {
+		f(&s.opts)
+	}
+	s.pool = newLuaStatePool(s.newState, s.opts.poolSize)
+
+	// Evaluate the script eagerly to report errors on the top level.
+	state, err := s.pool.get()
+	if err != nil {
+		return nil, errors.Join(ErrLuaInvalidScript, err)
+	}
+	s.pool.put(state)
+	return s, nil
+}
+
+func compileLua(r io.Reader, name string) (*lua.FunctionProto, error) {
+	chunk, err := parse.Parse(r, name)
+	if err != nil {
+		return nil, err
+	}
+	return lua.Compile(chunk, name)
+}
+
+func (s *LuaScript) newState() (*lua.LState, error) {
+	state := s.openState()
+	state.PreloadModule(luaModuleName, openLuaModule)
+	setLuaPath(state, s.opts.paths)
+	if s.opts.sandbox {
+		restrictLuaLoader(state, s.opts.paths)
+	}
+	state.Push(state.NewFunctionFromProto(s.proto))
+	if err := state.PCall(0, lua.MultRet, nil); err != nil {
+		state.Close()
+		return nil, err
+	}
+	return state, nil
+}
+
+// sandboxLuaLibs are the libraries available in the sandbox.
+var sandboxLuaLibs = []struct {
+	name string
+	open lua.LGFunction
+}{
+	{lua.LoadLibName, lua.OpenPackage},
+	{lua.BaseLibName, lua.OpenBase},
+	{lua.TabLibName, lua.OpenTable},
+	{lua.StringLibName, lua.OpenString},
+	{lua.MathLibName, lua.OpenMath},
+	{lua.CoroutineLibName, lua.OpenCoroutine},
+}
+
+// sandboxLuaBaseBlocked are the functions of the base library removed in the sandbox.
+var sandboxLuaBaseBlocked = []string{"dofile", "loadfile"}
+
+func (s *LuaScript) openState() *lua.LState {
+	if !s.opts.sandbox {
+		return lua.NewState()
+	}
+	state := lua.NewState(lua.Options{
+		SkipOpenLibs: true,
+	})
+	for _, lib := range sandboxLuaLibs {
+		state.Push(state.NewFunction(lib.open))
+		state.Push(lua.LString(lib.name))
+		state.Call(1, 0)
+	}
+	// They read arbitrary files.
+	for _, name := range sandboxLuaBaseBlocked {
+		state.SetGlobal(name, lua.LNil)
+	}
+	return state
 }
 
 func (s *LuaScript) Close() {
-	s.state.Close()
+	s.pool.close()
 }
 
 var (
@@ -51,19 +178,32 @@ var (
 )
 
 func (s *LuaScript) Run(src string) ([]string, error) {
-	s.mux.Lock()
-	defer s.mux.Unlock()
+	state, err := s.pool.get()
+	if err != nil {
+		return nil, errors.Join(ErrLuaInvalidCall, err)
+	}
 
-	if err := s.state.CallByParam(lua.P{
-		Fn:      s.state.GetGlobal(s.entryPoint),
+	result, err := s.call(state, src)
+	if err != nil {
+		// The state may be broken, do not reuse it.
+		s.pool.discard(state)
+		return nil, err
+	}
+	s.pool.put(state)
+	return result, nil
+}
+
+func (s *LuaScript) call(state *lua.LState, src string) ([]string, error) {
+	if err := state.CallByParam(lua.P{
+		Fn:      state.GetGlobal(s.entryPoint),
 		NRet:    1,
 		Protect: true,
 	}, lua.LString(src)); err != nil {
 		return nil, errors.Join(ErrLuaInvalidCall, err)
 	}
 
-	lRet := s.state.Get(-1)
-	s.state.Pop(1)
+	lRet := state.Get(-1)
+	state.Pop(1)
 
 	lStr, ok := lRet.(lua.LString)
 	if !ok {
@@ -73,3 +213,216 @@ func (s *LuaScript) Run(src string) ([]string, error) {
 	result := strings.Split(lStr.String(), "\n")
 	return result, nil
 }
+
+// luaStatePool holds idle states.
+// The pool grows as needed, so the number of states is up to the number of concurrent callers,
+// and keeps up to size idle states if size is positive.
+type luaStatePool struct {
+	newState func() (*lua.LState, error)
+	size     int
+	idle     []*lua.LState
+	inUse    int
+	maxIdle  int // peak of idle states
+	maxInUse int // peak of states in use
+	closed   bool
+	mux      sync.Mutex
+}
+
+func newLuaStatePool(newState func() (*lua.LState, error), size int) *luaStatePool {
+	return &luaStatePool{
+		newState: newState,
+		size:     size,
+	}
+}
+
+var (
+	ErrLuaPoolClosed = errors.New("LuaPoolClosed")
+)
+
+func (p *luaStatePool) get() (*lua.LState, error) {
+	p.mux.Lock()
+	if p.closed {
+		p.mux.Unlock()
+		return nil, ErrLuaPoolClosed
+	}
+	p.inUse++
+	p.maxInUse = max(p.maxInUse, p.inUse)
+	if n := len(p.idle); n > 0 {
+		state := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mux.Unlock()
+		return state, nil
+	}
+	p.mux.Unlock()
+
+	state, err := AddMetric("lua-state-new", p.newState)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+
+	AddMetricCount("lua-state-created", 1)
+	return state, nil
+}
+
+// release marks a state got from the pool as no longer in use.
+func (p *luaStatePool) release() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.inUse--
+}
+
+func (p *luaStatePool) put(state *lua.LState) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.inUse--
+	if p.closed {
+		state.Close()
+		return
+	}
+	if p.size > 0 && len(p.idle) >= p.size {
+		AddMetricCount("lua-state-pool-full", 1)
+		state.Close()
+		return
+	}
+	p.idle = append(p.idle, state)
+	p.maxIdle = max(p.maxIdle, len(p.idle))
+}
+
+func (p *luaStatePool) discard(state *lua.LState) {
+	AddMetricCount("lua-state-pool-discard", 1)
+	p.release()
+	state.Close()
+}
+
+// close closes the idle states and reports the peaks of idle states and states in use.
+func (p *luaStatePool) close() {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	for _, state := range p.idle {
+		state.Close()
+	}
+	p.idle = nil
+	AddMetricCount("lua-state-idle-max", uint64(p.maxIdle))
+	AddMetricCount("lua-state-in-use-max", uint64(p.maxInUse))
+}
+
+const (
+	luaHookOnFileBegin = "on_file_begin"
+	luaHookOnLine      = "on_line"
+	luaHookOnFileEnd   = "on_file_end"
+)
+
+// LuaFile is a lua script bound to a file.
+//
+// It calls the hooks on a state dedicated to the file, so the script can keep state between lines:
+//
+//	on_file_begin(path)   -- optional
+//	on_line(line, ctx)    -- returns a string or nil
+//	on_file_end()         -- optional
+//
+// ctx has path, linum, category and vars.
+type LuaFile struct {
+	script *LuaScript
+	state  *lua.LState
+	env    *lua.LTable // global variables of the file
+	broken bool
+}
+
+// BeginFile binds a state to the file and calls on_file_begin.
+// The script is evaluated again in a new environment of global variables per file,
+// so global variables set in the script and the hooks do not leak to other files.
+// The standard libraries, the modules loaded by require and the table _G are shared.
+func (s *LuaScript) BeginFile(path string) (*LuaFile, error) {
+	state, err := s.pool.get()
+	if err != nil {
+		return nil, errors.Join(ErrLuaInvalidCall, err)
+	}
+	env := state.NewTable()
+	meta := state.NewTable()
+	meta.RawSetString("__index", state.G.Global)
+	state.SetMetatable(env, meta)
+	fn := state.NewFunctionFromProto(s.proto)
+	fn.Env = env
+	state.Push(fn)
+	if err := state.PCall(0, lua.MultRet, nil); err != nil {
+		s.pool.discard(state)
+		return nil, errors.Join(ErrLuaInvalidCall, err)
+	}
+
+	f := &LuaFile{
+		script: s,
+		state:  state,
+		env:    env,
+	}
+	if _, err := f.callHook(luaHookOnFileBegin, lua.LString(path)); err != nil {
+		s.pool.discard(state)
+		return nil, err
+	}
+	return f, nil
+}
+
+// callHook calls the global function name, returns nil if it is not defined.
+func (f *LuaFile) callHook(name string, args ...lua.LValue) (lua.LValue, error) {
+	fn := f.state.GetField(f.env, name)
+	if fn.Type() != lua.LTFunction {
+		return lua.LNil, nil
+	}
+	if err := f.state.CallByParam(lua.P{
+		Fn:      fn,
+		NRet:    1,
+		Protect: true,
+	}, args...); err != nil {
+		f.broken = true
+		return nil, errors.Join(ErrLuaInvalidCall, err)
+	}
+
+	ret := f.state.Get(-1)
+	f.state.Pop(1)
+	return ret, nil
+}
+
+// Line calls on_line.
+func (f *LuaFile) Line(line string, scope *Scope) ([]string, error) {
+	if f.state.GetField(f.env, luaHookOnLine).Type() != lua.LTFunction {
+		return nil, fmt.Errorf("%w: %s is not defined", ErrLuaInvalidCall, luaHookOnLine)
+	}
+
+	ctx := f.state.NewTable()
+	ctx.RawSetString("path", lua.LString(scope.Path))
+	ctx.RawSetString("linum", lua.LNumber(scope.Line.Linum))
+	ctx.RawSetString("category", lua.LString(scope.Category))
+	vars := f.state.NewTable()
+	for k, v := range scope.Vars {
+		vars.RawSetString(k, lua.LString(v))
+	}
+	ctx.RawSetString("vars", vars)
+	ret, err := f.callHook(luaHookOnLine, lua.LString(line), ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ret := ret.(type) {
+	case *lua.LNilType:
+		return nil, ErrUnmatched
+	case lua.LString:
+		return strings.Split(ret.String(), "\n"), nil
+	default:
+		return nil, fmt.Errorf("%w: return type %s but should be String or Nil", ErrLuaInvalidReturnType, ret.Type())
+	}
+}
+
+// End calls on_file_end and releases the state.
+func (f *LuaFile) End() error {
+	_, err := f.callHook(luaHookOnFileEnd)
+	if f.broken {
+		f.script.pool.discard(f.state)
+		return err
+	}
+	f.script.pool.put(f.state)
+	return nil
+}
@@ -0,0 +1,500 @@
+package grdep
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+	"gopkg.in/yaml.v3"
+)
+
+const luaModuleName = "grdep"
+
+// openLuaModule is the loader of the grdep module.
+//
+//	local grdep = require("grdep")
+func openLuaModule(ls *lua.LState) int {
+	mod := ls.SetFuncs(ls.NewTable(), map[string]lua.LGFunction{
+		"match":      luaRegexpMatch,
+		"find":       luaRegexpFind,
+		"find_all":   luaRegexpFindAll,
+		"replace":    luaRegexpReplace,
+		"split":      luaStringSplit,
+		"trim":       luaStringTrim,
+		"fields":     luaStringFields,
+		"has_prefix": luaStringHasPrefix,
+		"has_suffix": luaStringHasSuffix,
+	})
+	ls.SetField(mod, "path", ls.SetFuncs(ls.NewTable(), map[string]lua.LGFunction{
+		"base":   luaPathBase,
+		"dir":    luaPathDir,
+		"ext":    luaPathExt,
+		"join":   luaPathJoin,
+		"clean":  luaPathClean,
+		"split":  luaPathSplit,
+		"rel":    luaPathRel,
+		"is_abs": luaPathIsAbs,
+		"match":  luaPathMatch,
+	}))
+	ls.SetField(mod, "json", ls.SetFuncs(ls.NewTable(), map[string]lua.LGFunction{
+		"decode": luaJSONDecode,
+		"encode": luaJSONEncode,
+	}))
+	ls.SetField(mod, "yaml", ls.SetFuncs(ls.NewTable(), map[string]lua.LGFunction{
+		"decode": luaYAMLDecode,
+		"encode": luaYAMLEncode,
+	}))
+	ls.SetField(mod, "semver", ls.SetFuncs(ls.NewTable(), map[string]lua.LGFunction{
+		"compare": luaSemverCompare,
+	}))
+	ls.SetField(mod, "log", ls.SetFuncs(ls.NewTable(), map[string]lua.LGFunction{
+		"debug": luaLog(func(msg string, args ...any) { L().Debug(msg, args...) }),
+		"info":  luaLog(func(msg string, args ...any) { L().Info(msg, args...) }),
+		"warn":  luaLog(func(msg string, args ...any) { L().Warn(msg, args...) }),
+		"error": luaLog(func(msg string, args ...any) { L().Error(msg, args...) }),
+	}))
+	ls.Push(mod)
+	return 1
+}
+
+type compiledRegexp struct {
+	r   *regexp.Regexp
+	err error
+}
+
+var compileLuaRegexp = CachedFunc(func(pattern string) compiledRegexp {
+	r, err := regexp.Compile(pattern)
+	return compiledRegexp{
+		r:   r,
+		err: err,
+	}
+})
+
+func luaCheckRegexp(ls *lua.LState, n int) *regexp.Regexp {
+	r := compileLuaRegexp(ls.CheckString(n))
+	if r.err != nil {
+		ls.ArgError(n, r.err.Error())
+	}
+	return r.r
+}
+
+// grdep.match(pattern, s) returns true if s matches the pattern.
+func luaRegexpMatch(ls *lua.LState) int {
+	r := luaCheckRegexp(ls, 1)
+	ls.Push(lua.LBool(r.MatchString(ls.CheckString(2))))
+	return 1
+}
+
+func luaSubmatches(ls *lua.LState, r *regexp.Regexp, submatches []string) *lua.LTable {
+	t := ls.NewTable()
+	for _, x := range submatches {
+		t.Append(lua.LString(x))
+	}
+	for i, name := range r.SubexpNames() {
+		if name != "" {
+			t.RawSetString(name, lua.LString(submatches[i]))
+		}
+	}
+	return t
+}
+
+// grdep.find(pattern, s) returns the leftmost match and its submatches, or nil.
+// Index 1 is the whole match, named submatches are also set by their names.
+func luaRegexpFind(ls *lua.LState) int {
+	r := luaCheckRegexp(ls, 1)
+	m := r.FindStringSubmatch(ls.CheckString(2))
+	if m == nil {
+		ls.Push(lua.LNil)
+		return 1
+	}
+	ls.Push(luaSubmatches(ls, r, m))
+	return 1
+}
+
+// grdep.find_all(pattern, s) returns all matches like grdep.find.
+func luaRegexpFindAll(ls *lua.LState) int {
+	r := luaCheckRegexp(ls, 1)
+	t := ls.NewTable()
+	for _, m := range r.FindAllStringSubmatch(ls.CheckString(2), -1) {
+		t.Append(luaSubmatches(ls, r, m))
+	}
+	ls.Push(t)
+	return 1
+}
+
+// grdep.replace(pattern, s, repl) replaces matches with repl, $1 and ${name} are expanded.
+func luaRegexpReplace(ls *lua.LState) int {
+	r := luaCheckRegexp(ls, 1)
+	ls.Push(lua.LString(r.ReplaceAllString(ls.CheckString(2), ls.CheckString(3))))
+	return 1
+}
+
+func luaStrings(ls *lua.LState, xs []string) *lua.LTable {
+	t := ls.NewTable()
+	for _, x := range xs {
+		t.Append(lua.LString(x))
+	}
+	return t
+}
+
+// grdep.split(s, sep)
+func luaStringSplit(ls *lua.LState) int {
+	ls.Push(luaStrings(ls, strings.Split(ls.CheckString(1), ls.CheckString(2))))
+	return 1
+}
+
+// grdep.trim(s [, cutset]) trims whitespaces or cutset.
+func luaStringTrim(ls *lua.LState) int {
+	s := ls.CheckString(1)
+	if ls.GetTop() > 1 {
+		ls.Push(lua.LString(strings.Trim(s, ls.CheckString(2))))
+		return 1
+	}
+	ls.Push(lua.LString(strings.TrimSpace(s)))
+	return 1
+}
+
+// grdep.fields(s) splits s around whitespaces.
+func luaStringFields(ls *lua.LState) int {
+	ls.Push(luaStrings(ls, strings.Fields(ls.CheckString(1))))
+	return 1
+}
+
+func luaStringHasPrefix(ls *lua.LState) int {
+	ls.Push(lua.LBool(strings.HasPrefix(ls.CheckString(1), ls.CheckString(2))))
+	return 1
+}
+
+func luaStringHasSuffix(ls *lua.LState) int {
+	ls.Push(lua.LBool(strings.HasSuffix(ls.CheckString(1), ls.CheckString(2))))
+	return 1
+}
+
+func luaPathBase(ls *lua.LState) int {
+	ls.Push(lua.LString(filepath.Base(ls.CheckString(1))))
+	return 1
+}
+
+func luaPathDir(ls *lua.LState) int {
+	ls.Push(lua.LString(filepath.Dir(ls.CheckString(1))))
+	return 1
+}
+
+func luaPathExt(ls *lua.LState) int {
+	ls.Push(lua.LString(filepath.Ext(ls.CheckString(1))))
+	return 1
+}
+
+// grdep.path.join(elem...)
+func luaPathJoin(ls *lua.LState) int {
+	elems := make([]string, ls.GetTop())
+	for i := range elems {
+		elems[i] = ls.CheckString(i + 1)
+	}
+	ls.Push(lua.LString(filepath.Join(elems...)))
+	return 1
+}
+
+func luaPathClean(ls *lua.LState) int {
+	ls.Push(lua.LString(filepath.Clean(ls.CheckString(1))))
+	return 1
+}
+
+// grdep.path.split(path) returns the directory and the file name.
+func luaPathSplit(ls *lua.LState) int {
+	dir, file := filepath.Split(ls.CheckString(1))
+	ls.Push(lua.LString(dir))
+	ls.Push(lua.LString(file))
+	return 2
+}
+
+// grdep.path.rel(base, target) returns the relative path or nil and an error message.
+func luaPathRel(ls *lua.LState) int {
+	r, err := filepath.Rel(ls.CheckString(1), ls.CheckString(2))
+	if err != nil {
+		ls.Push(lua.LNil)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	ls.Push(lua.LString(r))
+	return 1
+}
+
+func luaPathIsAbs(ls *lua.LState) int {
+	ls.Push(lua.LBool(filepath.IsAbs(ls.CheckString(1))))
+	return 1
+}
+
+// grdep.path.match(glob, name)
+func luaPathMatch(ls *lua.LState) int {
+	matched, err := filepath.Match(ls.CheckString(1), ls.CheckString(2))
+	if err != nil {
+		ls.ArgError(1, err.Error())
+	}
+	ls.Push(lua.LBool(matched))
+	return 1
+}
+
+// grdep.json.decode(s) returns the value or nil and an error message.
+func luaJSONDecode(ls *lua.LState) int {
+	var v any
+	if err := json.Unmarshal([]byte(ls.CheckString(1)), &v); err != nil {
+		ls.Push(lua.LNil)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	ls.Push(toLuaValue(ls, v))
+	return 1
+}
+
+// grdep.json.encode(v) returns the json string or nil and an error message.
+func luaJSONEncode(ls *lua.LState) int {
+	v, err := fromLuaValue(ls.CheckAny(1))
+	if err != nil {
+		ls.Push(lua.LNil)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		ls.Push(lua.LNil)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	ls.Push(lua.LString(b))
+	return 1
+}
+
+// grdep.yaml.decode(s) returns the value or nil and an error message.
+func luaYAMLDecode(ls *lua.LState) int {
+	var v any
+	if err := yaml.Unmarshal([]byte(ls.CheckString(1)), &v); err != nil {
+		ls.Push(lua.LNil)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	ls.Push(toLuaValue(ls, v))
+	return 1
+}
+
+// grdep.yaml.encode(v) returns the yaml string or nil and an error message.
+func luaYAMLEncode(ls *lua.LState) int {
+	v, err := fromLuaValue(ls.CheckAny(1))
+	if err != nil {
+		ls.Push(lua.LNil)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		ls.Push(lua.LNil)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	ls.Push(lua.LString(b))
+	return 1
+}
+
+// grdep.semver.compare(a, b) returns -1, 0 or 1, or nil and an error message.
+func luaSemverCompare(ls *lua.LState) int {
+	c, err := CompareSemver(ls.CheckString(1), ls.CheckString(2))
+	if err != nil {
+		ls.Push(lua.LNil)
+		ls.Push(lua.LString(err.Error()))
+		return 2
+	}
+	ls.Push(lua.LNumber(c))
+	return 1
+}
+
+// grdep.log.info(msg, key, value, ...)
+func luaLog(f func(msg string, args ...any)) lua.LGFunction {
+	return func(ls *lua.LState) int {
+		msg := ls.CheckString(1)
+		args := make([]any, 0, ls.GetTop()-1)
+		for i := 2; i <= ls.GetTop(); i++ {
+			v, err := fromLuaValue(ls.Get(i))
+			if err != nil {
+				v = err.Error()
+			}
+			args = append(args, v)
+		}
+		f(msg, args...)
+		return 0
+	}
+}
+
+func toLuaValue(ls *lua.LState, v any) lua.LValue {
+	switch v := v.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(v)
+	case string:
+		return lua.LString(v)
+	case int:
+		return lua.LNumber(v)
+	case int64:
+		return lua.LNumber(v)
+	case uint64:
+		return lua.LNumber(v)
+	case float64:
+		return lua.LNumber(v)
+	case []any:
+		t := ls.NewTable()
+		for _, x := range v {
+			t.Append(toLuaValue(ls, x))
+		}
+		return t
+	case map[string]any:
+		t := ls.NewTable()
+		for k, x := range v {
+			t.RawSetString(k, toLuaValue(ls, x))
+		}
+		return t
+	case map[any]any:
+		t := ls.NewTable()
+		for k, x := range v {
+			t.RawSetString(fmt.Sprint(k), toLuaValue(ls, x))
+		}
+		return t
+	default:
+		return lua.LString(fmt.Sprint(v))
+	}
+}
+
+// errCyclicTable is the error of fromLuaValue for a table that contains itself.
+var errCyclicTable = errors.New("cyclic table")
+
+// fromLuaValue converts a lua value into a go value.
+// A table with only 1..n keys becomes a slice, other tables become maps.
+// A table that contains itself is an error.
+func fromLuaValue(v lua.LValue) (any, error) {
+	return fromLuaValueOnPath(v, map[*lua.LTable]bool{})
+}
+
+// fromLuaValueOnPath converts v, path is the tables being converted.
+func fromLuaValueOnPath(v lua.LValue, path map[*lua.LTable]bool) (any, error) {
+	switch v := v.(type) {
+	case *lua.LNilType:
+		return nil, nil
+	case lua.LBool:
+		return bool(v), nil
+	case lua.LString:
+		return string(v), nil
+	case lua.LNumber:
+		return float64(v), nil
+	case *lua.LTable:
+		if path[v] {
+			return nil, errCyclicTable
+		}
+		path[v] = true
+		defer delete(path, v)
+
+		var (
+			n     = v.MaxN()
+			count int
+		)
+		v.ForEach(func(_, _ lua.LValue) {
+			count++
+		})
+		if n > 0 && n == count {
+			xs := make([]any, n)
+			for i := range n {
+				x, err := fromLuaValueOnPath(v.RawGetInt(i+1), path)
+				if err != nil {
+					return nil, err
+				}
+				xs[i] = x
+			}
+			return xs, nil
+		}
+		var (
+			m   = map[string]any{}
+			err error
+		)
+		v.ForEach(func(key, value lua.LValue) {
+			if err != nil {
+				return
+			}
+			var x any
+			x, err = fromLuaValueOnPath(value, path)
+			m[key.String()] = x
+		})
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	default:
+		return v.String(), nil
+	}
+}
+
+// setLuaPath prepends dirs to package.path to resolve require.
+func setLuaPath(ls *lua.LState, dirs []string) {
+	if len(dirs) == 0 {
+		return
+	}
+	pkg, ok := ls.GetGlobal("package").(*lua.LTable)
+	if !ok {
+		return
+	}
+	paths := []string{}
+	for _, dir := range dirs {
+		paths = append(paths,
+			filepath.Join(dir, "?.lua"),
+			filepath.Join(dir, "?", "init.lua"),
+		)
+	}
+	if p := lua.LVAsString(ls.GetField(pkg, "path")); p != "" {
+		paths = append(paths, p)
+	}
+	ls.SetField(pkg, "path", lua.LString(strings.Join(paths, ";")))
+}
+
+// restrictLuaLoader replaces the loader of lua files by the one that searches modules only in dirs,
+// so changing package.path cannot load other files.
+func restrictLuaLoader(ls *lua.LState, dirs []string) {
+	loaders, ok := ls.GetField(ls.Get(lua.RegistryIndex), "_LOADERS").(*lua.LTable)
+	if !ok {
+		return
+	}
+	ls.RawSetInt(loaders, 2, ls.NewFunction(func(ls *lua.LState) int {
+		name := ls.CheckString(1)
+		path, ok := findLuaModule(dirs, name)
+		if !ok {
+			ls.Push(lua.LString(fmt.Sprintf("module %s is not found in %s", name, strings.Join(dirs, ", "))))
+			return 1
+		}
+		f, err := ls.LoadFile(path)
+		if err != nil {
+			ls.RaiseError("%s", err.Error())
+		}
+		ls.Push(f)
+		return 1
+	}))
+}
+
+// findLuaModule returns the path of the module like require, name should be local to dirs.
+func findLuaModule(dirs []string, name string) (string, bool) {
+	rel := strings.ReplaceAll(name, ".", string(filepath.Separator))
+	if !filepath.IsLocal(rel) {
+		return "", false
+	}
+	for _, dir := range dirs {
+		for _, path := range []string{
+			filepath.Join(dir, rel+".lua"),
+			filepath.Join(dir, rel, "init.lua"),
+		} {
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,214 @@
+package grdep_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berquerant/grdep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLuaModule(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		src    string
+		script string
+		want   []string
+	}{
+		{
+			name: "match",
+			src:  "FROM debian",
+			script: `local grdep = require("grdep")
+function f(src)
+  return tostring(grdep.match("^FROM\\s", src))
+end`,
+			want: []string{"true"},
+		},
+		{
+			name: "find",
+			src:  "FROM debian:bookworm",
+			script: `local grdep = require("grdep")
+function f(src)
+  local m = grdep.find("^FROM (?P<image>[^:]+):(\\w+)$", src)
+  return m.image .. "\n" .. m[3]
+end`,
+			want: []string{"debian", "bookworm"},
+		},
+		{
+			name: "find unmatched",
+			src:  "RUN",
+			script: `local grdep = require("grdep")
+function f(src)
+  return tostring(grdep.find("^FROM", src))
+end`,
+			want: []string{"nil"},
+		},
+		{
+			name: "find_all",
+			src:  "/bin/a /bin/b",
+			script: `local grdep = require("grdep")
+function f(src)
+  local r = {}
+  for _, m in ipairs(grdep.find_all("/bin/(\\w+)", src)) do
+    table.insert(r, m[2])
+  end
+  return table.concat(r, "\n")
+end`,
+			want: []string{"a", "b"},
+		},
+		{
+			name: "replace",
+			src:  "a-b",
+			script: `local grdep = require("grdep")
+function f(src)
+  return grdep.replace("(\\w)-(\\w)", src, "$2-$1")
+end`,
+			want: []string{"b-a"},
+		},
+		{
+			name: "strings",
+			src:  " a b ",
+			script: `local grdep = require("grdep")
+function f(src)
+  return table.concat(grdep.fields(src), ",") .. "\n" .. grdep.trim(src) .. "\n" .. table.concat(grdep.split("x,y", ","), ":")
+end`,
+			want: []string{"a,b", "a b", "x:y"},
+		},
+		{
+			name: "path",
+			src:  "/usr/local/bin/app.sh",
+			script: `local grdep = require("grdep")
+function f(src)
+  local dir, file = grdep.path.split(src)
+  return table.concat({
+    grdep.path.base(src),
+    grdep.path.dir(src),
+    grdep.path.ext(src),
+    grdep.path.join("a", "b", "c"),
+    dir,
+    file,
+    grdep.path.rel("/usr", src),
+    tostring(grdep.path.match("*.sh", file)),
+  }, "\n")
+end`,
+			want: []string{"app.sh", "/usr/local/bin", ".sh", "a/b/c", "/usr/local/bin/", "app.sh", "local/bin/app.sh", "true"},
+		},
+		{
+			name: "json",
+			src:  `{"name":"x","deps":["a","b"]}`,
+			script: `local grdep = require("grdep")
+function f(src)
+  local v = grdep.json.decode(src)
+  return v.name .. "\n" .. v.deps[2] .. "\n" .. grdep.json.encode(v.deps)
+end`,
+			want: []string{"x", "b", `["a","b"]`},
+		},
+		{
+			name: "json invalid",
+			src:  `{`,
+			script: `local grdep = require("grdep")
+function f(src)
+  local v, err = grdep.json.decode(src)
+  return tostring(v) .. "\n" .. tostring(err ~= nil)
+end`,
+			want: []string{"nil", "true"},
+		},
+		{
+			name: "json cyclic",
+			src:  "x",
+			script: `local grdep = require("grdep")
+function f(src)
+  local t = {name = src}
+  t.self = t
+  local shared = {src}
+  local v, err = grdep.json.encode(t)
+  local y, yerr = grdep.yaml.encode(t)
+  grdep.log.info("cyclic", "t", t)
+  return tostring(v) .. "\n" .. err .. "\n" .. tostring(y) .. "\n" .. yerr .. "\n" .. grdep.json.encode({shared, shared})
+end`,
+			want: []string{"nil", "cyclic table", "nil", "cyclic table", `[["x"],["x"]]`},
+		},
+		{
+			name: "yaml",
+			src:  "deps:\n  - a\n  - b",
+			script: `local grdep = require("grdep")
+function f(src)
+  local v = grdep.yaml.decode(src)
+  return v.deps[1] .. "\n" .. grdep.yaml.encode({k = "v"})
+end`,
+			want: []string{"a", "k: v", ""},
+		},
+		{
+			name: "semver",
+			src:  "v1.2.3",
+			script: `local grdep = require("grdep")
+function f(src)
+  return grdep.semver.compare(src, "1.10.0") .. "\n" .. grdep.semver.compare(src, src)
+end`,
+			want: []string{"-1", "0"},
+		},
+		{
+			name: "log",
+			src:  "x",
+			script: `local grdep = require("grdep")
+function f(src)
+  grdep.log.debug("lua", "src", src)
+  return src
+end`,
+			want: []string{"x"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := grdep.NewLuaScript(tc.script, "f")
+			if !assert.Nil(t, err) {
+				return
+			}
+			defer s.Close()
+			got, err := s.Run(tc.src)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("require", func(t *testing.T) {
+		dir := t.TempDir()
+		if !assert.Nil(t, os.WriteFile(filepath.Join(dir, "util.lua"), []byte(`local M = {}
+function M.up(s)
+  return string.upper(s)
+end
+return M`), 0644)) {
+			return
+		}
+		const script = `local util = require("util")
+function f(src)
+  return util.up(src)
+end`
+
+		t.Run("path", func(t *testing.T) {
+			s, err := grdep.NewLuaScript(script, "f", grdep.WithLuaPath(dir))
+			if !assert.Nil(t, err) {
+				return
+			}
+			defer s.Close()
+			got, err := s.Run("a")
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"A"}, got)
+		})
+
+		t.Run("file", func(t *testing.T) {
+			fname := filepath.Join(dir, "main.lua")
+			if !assert.Nil(t, os.WriteFile(fname, []byte(script), 0644)) {
+				return
+			}
+			s, err := grdep.NewLuaScriptFromFile(fname, "f")
+			if !assert.Nil(t, err) {
+				return
+			}
+			defer s.Close()
+			got, err := s.Run("b")
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"B"}, got)
+		})
+	})
+}
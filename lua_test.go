@@ -1,11 +1,14 @@
 package grdep_test
 
 import (
+	"fmt"
 	"os"
+	"strconv"
 	"testing"
 
 	"github.com/berquerant/grdep"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/errgroup"
 )
 
 func TestLuaScript(t *testing.T) {
@@ -55,6 +58,94 @@ end`
 			})
 		})
 
+		t.Run("Concurrent", func(t *testing.T) {
+			const (
+				entryPoint = "f"
+				script     = `count = 0
+function f(src)
+  count = count + 1
+  return src .. "!"
+end`
+				concurrency = 4
+				seedSize    = 100
+			)
+			for _, size := range []int{0, 1} {
+				t.Run(fmt.Sprintf("pool size %d", size), func(t *testing.T) {
+					s, err := grdep.NewLuaScript(script, entryPoint, grdep.WithLuaPoolSize(size))
+					if !assert.Nil(t, err) {
+						return
+					}
+					defer s.Close()
+
+					var eg errgroup.Group
+					for j := 0; j < concurrency; j++ {
+						eg.Go(func() error {
+							for i := 0; i < seedSize; i++ {
+								src := strconv.Itoa(i)
+								got, err := s.Run(src)
+								if err != nil {
+									return err
+								}
+								if len(got) != 1 || got[0] != src+"!" {
+									return fmt.Errorf("unexpected result %v", got)
+								}
+							}
+							return nil
+						})
+					}
+					assert.Nil(t, eg.Wait())
+				})
+			}
+		})
+
+		t.Run("Sandbox", func(t *testing.T) {
+			var (
+				dir     = t.TempDir()
+				outside = t.TempDir()
+			)
+			if !assert.Nil(t, os.WriteFile(dir+"/mod.lua", []byte(`return {v = "in"}`), 0644)) {
+				return
+			}
+			if !assert.Nil(t, os.WriteFile(outside+"/secret.lua", []byte(`return {v = "out"}`), 0644)) {
+				return
+			}
+			script := `function f(src)
+  local r = {type(dofile), type(loadfile), type(os), type(io), require("mod").v}
+  package.path = "` + outside + `/?.lua;" .. package.path
+  local ok = pcall(require, "secret")
+  table.insert(r, tostring(ok))
+  return table.concat(r, "\n")
+end`
+
+			for _, tc := range []struct {
+				name string
+				opt  []grdep.LuaOption
+				want []string
+			}{
+				{
+					name: "sandbox",
+					opt:  []grdep.LuaOption{grdep.WithLuaPath(dir), grdep.WithLuaSandbox()},
+					want: []string{"nil", "nil", "nil", "nil", "in", "false"},
+				},
+				{
+					name: "no sandbox",
+					opt:  []grdep.LuaOption{grdep.WithLuaPath(dir)},
+					want: []string{"function", "function", "table", "table", "in", "true"},
+				},
+			} {
+				t.Run(tc.name, func(t *testing.T) {
+					s, err := grdep.NewLuaScript(script, "f", tc.opt...)
+					if !assert.Nil(t, err) {
+						return
+					}
+					defer s.Close()
+					got, err := s.Run("")
+					assert.Nil(t, err)
+					assert.Equal(t, tc.want, got)
+				})
+			}
+		})
+
 		t.Run("New", func(t *testing.T) {
 			for _, tc := range []struct {
 				name       string
@@ -0,0 +1,82 @@
+package grdep
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+var _ CategorySelectorIface = &MarkerCategorySelector{}
+
+// NewMarkerCategorySelector selects categories by the marker file found in the nearest ancestor directory.
+// The matcher receives the name of the marker file, and returns it as is if the matcher is nil.
+// The directory is set to the variable of the file.
+// The selector caches the existence of the marker files, so it should not outlive the walk of the files.
+func NewMarkerCategorySelector(files []string, variable string, matcher MatcherIface) *MarkerCategorySelector {
+	if variable == "" {
+		variable = VarProjectRoot
+	}
+	return &MarkerCategorySelector{
+		files:    files,
+		variable: variable,
+		matcher:  matcher,
+		// files in the same directory share ancestors
+		exists: CachedFunc(fileExists),
+	}
+}
+
+type MarkerCategorySelector struct {
+	files    []string
+	variable string
+	matcher  MatcherIface
+	exists   func(path string) bool
+}
+
+func (s MarkerCategorySelector) Close() error {
+	if s.matcher == nil {
+		return nil
+	}
+	return s.matcher.Close()
+}
+
+func (s MarkerCategorySelector) Select(file *File) ([]string, error) {
+	if file.Record > 0 {
+		// not on the filesystem
+		return nil, ErrUnmatched
+	}
+	dir, name, err := findMarker(file.Path, s.files, s.exists)
+	if err != nil {
+		return nil, fmt.Errorf("%w: marker category %s", err, file.Path)
+	}
+
+	r := []string{name}
+	if s.matcher != nil {
+		r, err = s.matcher.Match(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: marker category %s", err, file.Path)
+		}
+	}
+	file.SetVar(s.variable, dir)
+	return r, nil
+}
+
+// FindMarker finds the nearest ancestor directory of the path that contains any of the files.
+// Returns the directory and the name of the found file.
+// The directory is relative if the path is relative, even above the working directory.
+func FindMarker(path string, files []string) (string, string, error) {
+	return findMarker(path, files, fileExists)
+}
+
+func findMarker(path string, files []string, exists func(string) bool) (string, string, error) {
+	dirs, err := ancestorDirs(path)
+	if err != nil {
+		return "", "", err
+	}
+	for _, x := range dirs {
+		for _, name := range files {
+			if exists(filepath.Join(x.dir, name)) {
+				return x.dir, name, nil
+			}
+		}
+	}
+	return "", "", ErrUnmatched
+}
@@ -0,0 +1,107 @@
+package grdep_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/berquerant/grdep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarkerCategorySelector(t *testing.T) {
+	root := t.TempDir()
+	for _, x := range []string{
+		"go.mod",
+		"cmd/main.go",
+		"web/package.json",
+		"web/src/index.js",
+	} {
+		p := filepath.Join(root, x)
+		if !assert.Nil(t, os.MkdirAll(filepath.Dir(p), 0755)) {
+			return
+		}
+		if !assert.Nil(t, os.WriteFile(p, nil, 0644)) {
+			return
+		}
+	}
+
+	newRegexp := func(pattern string) *grdep.Regexp {
+		v := grdep.NewRegexp(pattern)
+		return &v
+	}
+	selector := grdep.NewMarkerCategorySelector(
+		[]string{"go.mod", "package.json"},
+		"",
+		grdep.MatcherSet([]*grdep.Matcher{
+			{
+				Regex:    newRegexp(`^(?P<v>\w+)\.`),
+				Template: "$v-project",
+			},
+		}),
+	)
+	defer selector.Close()
+
+	for _, tc := range []struct {
+		name string
+		path string
+		want []string
+		root string
+	}{
+		{
+			name: "go",
+			path: "cmd/main.go",
+			want: []string{"go-project"},
+			root: root,
+		},
+		{
+			name: "nearest",
+			path: "web/src/index.js",
+			want: []string{"package-project"},
+			root: filepath.Join(root, "web"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := grdep.OpenFile(filepath.Join(root, tc.path), nil)
+			got, err := selector.Select(f)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, map[string]string{grdep.VarProjectRoot: tc.root}, f.Vars)
+		})
+	}
+
+	t.Run("cache per selector", func(t *testing.T) {
+		var (
+			files = []string{"Cargo.toml"}
+			path  = filepath.Join(root, "cmd", "main.go")
+		)
+		before := grdep.NewMarkerCategorySelector(files, "", nil)
+		defer before.Close()
+		_, err := before.Select(grdep.OpenFile(path, nil))
+		assert.ErrorIs(t, err, grdep.ErrUnmatched)
+
+		if !assert.Nil(t, os.WriteFile(filepath.Join(root, "Cargo.toml"), nil, 0644)) {
+			return
+		}
+		after := grdep.NewMarkerCategorySelector(files, "", nil)
+		defer after.Close()
+		got, err := after.Select(grdep.OpenFile(path, nil))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"Cargo.toml"}, got)
+	})
+
+	t.Run("record", func(t *testing.T) {
+		f := grdep.OpenFile(filepath.Join(root, "cmd/main.go"), nil)
+		f.Record = 1
+		_, err := selector.Select(f)
+		assert.ErrorIs(t, err, grdep.ErrUnmatched)
+	})
+
+	t.Run("relative", func(t *testing.T) {
+		t.Chdir(filepath.Join(root, "web", "src"))
+		dir, name, err := grdep.FindMarker("index.js", []string{"go.mod"})
+		assert.Nil(t, err)
+		assert.Equal(t, "go.mod", name)
+		assert.Equal(t, filepath.Join("..", ".."), dir)
+	})
+}
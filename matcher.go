@@ -24,8 +24,12 @@ func NewNamedMatcherSet(name string, matcherSet MatcherSet) *NamedMatcherSet {
 }
 
 func (m NamedMatcherSet) Match(src string) ([]string, error) {
+	return m.MatchScope(nil, src)
+}
+
+func (m NamedMatcherSet) MatchScope(scope *Scope, src string) ([]string, error) {
 	return AddMetric(fmt.Sprintf("named-matcher-set-%s", m.name), func() ([]string, error) {
-		return m.MatcherSet.Match(src)
+		return m.MatcherSet.MatchScope(scope, src)
 	})
 }
 
@@ -39,25 +43,36 @@ func (m MatcherSet) Close() error {
 }
 
 func (m MatcherSet) Match(src string) ([]string, error) {
+	return m.MatchScope(nil, src)
+}
+
+func (m MatcherSet) MatchScope(scope *Scope, src string) ([]string, error) {
 	if len(m) == 0 {
 		return nil, ErrUnmatched
 	}
 
 	result := []string{src}
 	for i, x := range m {
-		acc := []string{}
+		var (
+			acc     = []string{}
+			blocked bool // keep ErrExecNotAllowed to report the matcher is not executed
+		)
 		for _, y := range result {
-			r, err := x.Match(y)
+			r, err := x.MatchScope(scope, y)
 			OnDebug(func() {
 				b, _ := json.Marshal(x)
 				L().Debug("matcher", "index", i, "body", string(b), "src", y, "ret", r, "err", err)
 			})
 			if err != nil {
+				blocked = blocked || errors.Is(err, ErrExecNotAllowed)
 				continue
 			}
 			acc = append(acc, r...)
 		}
 		if len(acc) == 0 {
+			if blocked {
+				return nil, fmt.Errorf("%w: %w: matcher set[%d]", ErrUnmatched, ErrExecNotAllowed, i)
+			}
 			return nil, fmt.Errorf("%w: matcher set[%d]", ErrUnmatched, i)
 		}
 		result = acc
@@ -83,7 +98,13 @@ var (
 )
 
 func (m *Matcher) Match(src string) ([]string, error) {
-	r, err := m.internalMatch(src)
+	return m.MatchScope(nil, src)
+}
+
+// MatchScope matches src in the scope.
+// Lua hooks keep their state per scope, other matchers ignore the scope.
+func (m *Matcher) MatchScope(scope *Scope, src string) ([]string, error) {
+	r, err := m.internalMatch(scope, src)
 	if err != nil {
 		return nil, err
 	}
@@ -96,8 +117,12 @@ func (m *Matcher) Match(src string) ([]string, error) {
 	return r, nil
 }
 
-func (m *Matcher) internalMatch(src string) ([]string, error) {
+func (m *Matcher) internalMatch(scope *Scope, src string) ([]string, error) {
 	switch {
+	case m.LuaHooks:
+		return AddMetric("matcher-lua-hooks", func() ([]string, error) {
+			return m.runLuaHooks(scope, src)
+		})
 	case m.LuaEntryPoint != "":
 		return AddMetric("matcher-lua", func() ([]string, error) {
 			return m.runLua(src)
@@ -116,7 +141,7 @@ func (m *Matcher) internalMatch(src string) ([]string, error) {
 		})
 	case m.Template != "":
 		return AddMetric("matcher-template", func() ([]string, error) {
-			return m.expand(src)
+			return m.expand(scope, src)
 		})
 	case m.Regex != nil:
 		return AddMetric("matcher-regex", func() ([]string, error) {
@@ -163,10 +188,17 @@ func (m *Matcher) match(src string) ([]string, error) {
 	return nil, ErrUnmatched
 }
 
-func (m *Matcher) expand(src string) ([]string, error) {
-	result := []byte{}
-	for _, submatches := range m.Regex.Unwrap().FindAllStringSubmatchIndex(src, -1) {
-		result = m.Regex.Unwrap().ExpandString(result, m.Template, src, submatches)
+func (m *Matcher) expand(scope *Scope, src string) ([]string, error) {
+	var (
+		re       = m.Regex.Unwrap()
+		template = m.Template
+		result   = []byte{}
+	)
+	if scope != nil {
+		template = expandVars(template, re, scope.Vars)
+	}
+	for _, submatches := range re.FindAllStringSubmatchIndex(src, -1) {
+		result = re.ExpandString(result, template, src, submatches)
 	}
 	if len(result) == 0 {
 		return nil, ErrUnmatched
@@ -187,7 +219,15 @@ func (m *Matcher) prepareShell() {
 	m.shellScript = NewShellScript(m.Shell, "bash")
 }
 
+var (
+	// ErrExecNotAllowed is the error of a sh matcher blocked by the sandbox, it is also ErrUnmatched.
+	ErrExecNotAllowed = errors.New("ExecNotAllowed")
+)
+
 func (m *Matcher) runShell(src string) ([]string, error) {
+	if m.sandboxed {
+		return nil, errors.Join(ErrUnmatched, ErrExecNotAllowed)
+	}
 	r, err := m.internalRunShell(src)
 	if err != nil {
 		return nil, errors.Join(ErrUnmatched, err)
@@ -214,6 +254,18 @@ func (m *Matcher) glob(src string) ([]string, error) {
 	return []string{src}, nil
 }
 
+// luaDirs returns the directories where require searches modules, in the order of the search.
+func (m *Matcher) luaDirs() []string {
+	var dirs []string
+	if m.LuaFile != "" {
+		dirs = append(dirs, filepath.Dir(m.LuaFile))
+	}
+	if m.dir != "" {
+		dirs = append(dirs, m.dir)
+	}
+	return dirs
+}
+
 func (m *Matcher) prepareLua() error {
 	m.mux.Lock()
 	defer m.mux.Unlock()
@@ -222,13 +274,27 @@ func (m *Matcher) prepareLua() error {
 		return nil
 	}
 	var (
-		s   *LuaScript
-		err error
+		s    *LuaScript
+		err  error
+		opts = []LuaOption{WithLuaPath(m.dir), WithLuaPoolSize(m.luaPoolSize)}
 	)
-	if m.LuaFile != "" {
-		s, err = NewLuaScriptFromFile(m.LuaFile, m.LuaEntryPoint)
-	} else {
-		s, err = NewLuaScript(m.Lua, m.LuaEntryPoint)
+	if m.sandboxed {
+		opts = append(opts, WithLuaSandbox())
+	}
+	entryPoint := m.LuaEntryPoint
+	if m.LuaHooks {
+		entryPoint = luaHookOnLine
+	}
+	switch {
+	case m.luaFileErr != nil:
+		return errors.Join(ErrLuaInvalidScript, m.luaFileErr)
+	case m.luaFileContent != nil:
+		// The script read when the config is parsed, that the hash of the config includes.
+		s, err = newLuaScriptFromFileContent(m.LuaFile, m.luaFileContent, entryPoint, opts...)
+	case m.LuaFile != "":
+		s, err = NewLuaScriptFromFile(m.LuaFile, entryPoint, opts...)
+	default:
+		s, err = NewLuaScript(m.Lua, entryPoint, opts...)
 	}
 	if err != nil {
 		return err
@@ -251,3 +317,45 @@ func (m *Matcher) internalRunLua(src string) ([]string, error) {
 	}
 	return m.luaScript.Run(src)
 }
+
+func (m *Matcher) runLuaHooks(scope *Scope, src string) ([]string, error) {
+	r, err := m.internalRunLuaHooks(scope, src)
+	if err != nil {
+		return nil, errors.Join(ErrUnmatched, err)
+	}
+	return r, nil
+}
+
+type luaHooksScope struct {
+	file *LuaFile
+	err  error
+}
+
+func (m *Matcher) internalRunLuaHooks(scope *Scope, src string) ([]string, error) {
+	if err := m.prepareLua(); err != nil {
+		return nil, err
+	}
+	if scope == nil {
+		// no file, call all hooks for the line
+		scope = NewScope("", "")
+		defer scope.Close()
+	}
+
+	v, ok := scope.Value(m)
+	if !ok {
+		f, err := m.luaScript.BeginFile(scope.Path)
+		v = &luaHooksScope{
+			file: f,
+			err:  err,
+		}
+		scope.SetValue(m, v)
+		if err == nil {
+			scope.OnClose(f.End)
+		}
+	}
+	s, _ := v.(*luaHooksScope)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.file.Line(src, scope)
+}
@@ -164,3 +164,130 @@ func (f MockMatcherFunc) Match(_ string) ([]string, error) {
 func (MockMatcherFunc) Close() error {
 	return nil
 }
+
+func TestMatcherLuaHooks(t *testing.T) {
+	m := &grdep.Matcher{
+		Lua: `local section = nil
+local count = 0
+function on_file_begin(path)
+  count = 0
+end
+function on_line(line, ctx)
+  count = count + 1
+  local m = string.match(line, "^%[(.+)%]$")
+  if m then
+    section = m
+    return nil
+  end
+  if section == "deps" then
+    return ctx.path .. ":" .. ctx.linum .. ":" .. ctx.category .. ":" .. line .. ":" .. count
+  end
+  return nil
+end`,
+		LuaHooks: true,
+	}
+	defer m.Close()
+	if !assert.Nil(t, m.Validate()) {
+		return
+	}
+
+	scanWith := func(m *grdep.Matcher, path string, lines []string) []string {
+		scope := grdep.NewScope(path, "ini")
+		defer scope.Close()
+		result := []string{}
+		for i, line := range lines {
+			scope.Line = grdep.Line{
+				Linum:   i + 1,
+				Content: line,
+				Path:    path,
+			}
+			r, err := m.MatchScope(scope, line)
+			if err != nil {
+				assert.ErrorIs(t, err, grdep.ErrUnmatched)
+				continue
+			}
+			result = append(result, r...)
+		}
+		return result
+	}
+	scan := func(path string, lines []string) []string {
+		return scanWith(m, path, lines)
+	}
+
+	lines := []string{"a", "[deps]", "x", "[other]", "y"}
+	assert.Equal(t, []string{"f1:3:ini:x:3"}, scan("f1", lines))
+	// the state is initialized per file
+	assert.Equal(t, []string{"f2:3:ini:x:3"}, scan("f2", lines))
+
+	t.Run("globals in hooks", func(t *testing.T) {
+		m := &grdep.Matcher{
+			Lua: `function on_line(line, ctx)
+  if seen then
+    return "seen " .. line
+  end
+  seen = true
+  return nil
+end`,
+			LuaHooks: true,
+		}
+		defer m.Close()
+		if !assert.Nil(t, m.Validate()) {
+			return
+		}
+		lines := []string{"x", "y"}
+		assert.Equal(t, []string{"seen y"}, scanWith(m, "f1", lines))
+		assert.Equal(t, []string{"seen y"}, scanWith(m, "f2", lines))
+	})
+}
+
+func TestMatcherTemplateVars(t *testing.T) {
+	vars := map[string]string{
+		grdep.VarProjectRoot: "/src$1",
+		"v":                  "shadowed",
+	}
+
+	for _, tc := range []struct {
+		name     string
+		regex    string
+		template string
+		src      string
+		want     []string
+	}{
+		{
+			name:     "vars",
+			regex:    `^import (?P<v>\S+)$`,
+			template: "${project_root}/$v $$ ${unknown}",
+			src:      "import fmt",
+			want:     []string{"/src$1/fmt $ "},
+		},
+		{
+			name:     "numbered submatches",
+			regex:    `^(a)(b)(c)(d)(e)(f)(g)(h)(i)(j)(k)(l)$`,
+			template: "$12 ${1}2 $project_root",
+			src:      "abcdefghijkl",
+			want:     []string{"l a2 /src$1"},
+		},
+		{
+			name:     "not names",
+			regex:    `^(?P<v>.+)$`,
+			template: "$# $@ ${project_root $ $v",
+			src:      "x",
+			want:     []string{"$# $@ ${project_root $ x"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := grdep.NewRegexp(tc.regex)
+			m := &grdep.Matcher{
+				Regex:    &r,
+				Template: tc.template,
+			}
+			defer m.Close()
+
+			scope := grdep.NewScope("main.go", "go")
+			scope.Vars = vars
+			got, err := m.MatchScope(scope, tc.src)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -10,6 +10,7 @@ type GaugeMapIface interface {
 	Walk(f func(key string, value *GaugeMapElement))
 	Close()
 	Incr(key string, f func() (any, error)) (any, error)
+	Add(key string, count uint64)
 }
 
 var (
@@ -28,6 +29,7 @@ func (*NullGaugeMap) Close()                                          {}
 func (*NullGaugeMap) Incr(_ string, f func() (any, error)) (any, error) {
 	return f()
 }
+func (*NullGaugeMap) Add(_ string, _ uint64) {}
 
 type GaugeMap struct {
 	d     map[string]*GaugeMapElement
@@ -104,6 +106,13 @@ func (g *GaugeMap) Incr(key string, f func() (any, error)) (any, error) {
 	return ret, err
 }
 
+func (g *GaugeMap) Add(key string, count uint64) {
+	g.addC <- &addGaugeMapRequest{
+		key:   key,
+		count: count,
+	}
+}
+
 func newGaugeMap() *GaugeMap {
 	g := &GaugeMap{
 		d:     map[string]*GaugeMapElement{},
@@ -133,6 +142,11 @@ func AddMetric[T any](key string, f func() (T, error)) (T, error) {
 	return v.(T), err
 }
 
+// AddMetricCount adds count to the metric without measuring duration.
+func AddMetricCount(key string, count uint64) {
+	metricGaugeMap.Add(key, count)
+}
+
 func GetMetrics() GaugeMapIface {
 	return metricGaugeMap
 }
@@ -1,6 +1,9 @@
 package grdep
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
 
 type Named interface {
 	GetName() string
@@ -22,8 +25,12 @@ func (m NamedMatcher) Close() error {
 }
 
 func (m NamedMatcher) Match(src string) ([]string, error) {
+	return m.MatchScope(nil, src)
+}
+
+func (m NamedMatcher) MatchScope(scope *Scope, src string) ([]string, error) {
 	r, err := AddMetric(fmt.Sprintf("named-matcher-%s", m.Name), func() ([]string, error) {
-		return MatcherSet(m.Matcher).Match(src)
+		return MatcherSet(m.Matcher).MatchScope(scope, src)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("%w: %s", err, m.Name)
@@ -38,16 +45,30 @@ type NamedSelectorResult struct {
 	Err    error  `json:"err,omitempty"`
 }
 
-func NewNamedCategorySelector(name string, selector CategorySelectorIface) *NamedCategorySelector {
-	return &NamedCategorySelector{
+type NamedCategorySelectorOption func(*NamedCategorySelector)
+
+// WithCategoryPriority sets the priority used in CategoryModePriority.
+func WithCategoryPriority(priority int) NamedCategorySelectorOption {
+	return func(s *NamedCategorySelector) {
+		s.priority = priority
+	}
+}
+
+func NewNamedCategorySelector(name string, selector CategorySelectorIface, opt ...NamedCategorySelectorOption) *NamedCategorySelector {
+	s := &NamedCategorySelector{
 		name:     name,
 		selector: selector,
 	}
+	for _, f := range opt {
+		f(s)
+	}
+	return s
 }
 
 type NamedCategorySelector struct {
 	name     string
 	selector CategorySelectorIface
+	priority int
 }
 
 func (s NamedCategorySelector) GetName() string {
@@ -61,9 +82,9 @@ func (s NamedCategorySelector) Close() error {
 	return s.selector.Close()
 }
 
-func (s NamedCategorySelector) Select(path string) ([]string, error) {
+func (s NamedCategorySelector) Select(file *File) ([]string, error) {
 	category, err := AddMetric(fmt.Sprintf("named-category-selector-%s", s.name), func() ([]string, error) {
-		return s.selector.Select(path)
+		return s.selector.Select(file)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("%w: category(%s)", err, s.name)
@@ -80,10 +101,46 @@ func (s NamedCategorySelectors) Close() error {
 	return nil
 }
 
-func (s NamedCategorySelectors) Select(path string) []NamedSelectorResult {
-	result := []NamedSelectorResult{}
-	for i, x := range s {
-		rs, err := x.Select(path)
+// Select returns the results of all the selectors.
+func (s NamedCategorySelectors) Select(file *File) []NamedSelectorResult {
+	return s.SelectMode(file, CategoryModeAll)
+}
+
+const (
+	// CategoryModeAll selects categories of all matched selectors.
+	CategoryModeAll = "all"
+	// CategoryModeFirst selects categories of the first matched selector.
+	CategoryModeFirst = "first"
+	// CategoryModePriority selects categories of the matched selectors with the highest priority.
+	CategoryModePriority = "priority"
+)
+
+// SelectMode returns the results of the selectors according to the mode.
+// Selectors after the decision are not evaluated.
+func (s NamedCategorySelectors) SelectMode(file *File, mode string) []NamedSelectorResult {
+	indexes := make([]int, len(s))
+	for i := range s {
+		indexes[i] = i
+	}
+	if mode == CategoryModePriority {
+		sort.SliceStable(indexes, func(i, j int) bool {
+			return s[indexes[i]].priority > s[indexes[j]].priority
+		})
+	}
+
+	var (
+		result  = []NamedSelectorResult{}
+		matched *NamedCategorySelector
+	)
+	for _, i := range indexes {
+		x := s[i]
+		if matched != nil {
+			if mode == CategoryModeFirst || x.priority != matched.priority {
+				break
+			}
+		}
+
+		rs, err := x.Select(file)
 		if err != nil {
 			result = append(result, NamedSelectorResult{
 				Index: i,
@@ -99,6 +156,9 @@ func (s NamedCategorySelectors) Select(path string) []NamedSelectorResult {
 				Result: r,
 			})
 		}
+		if mode != CategoryModeAll {
+			matched = x
+		}
 	}
 	return result
 }
@@ -127,8 +187,14 @@ func (s NamedNodeSelector) Close() error {
 }
 
 func (s NamedNodeSelector) Select(category, content string) ([]string, error) {
+	scope := NewScope("", category)
+	defer scope.Close()
+	return s.SelectScope(scope, content)
+}
+
+func (s NamedNodeSelector) SelectScope(scope *Scope, content string) ([]string, error) {
 	r, err := AddMetric(fmt.Sprintf("named-node-selector-%s", s.name), func() ([]string, error) {
-		return s.selector.Select(category, content)
+		return s.selector.SelectScope(scope, content)
 	})
 	if err != nil {
 		return nil, fmt.Errorf("%w: node(%s)", err, s.name)
@@ -146,9 +212,15 @@ func (s NamedNodeSelectors) Close() error {
 }
 
 func (s NamedNodeSelectors) Select(category, content string) []NamedSelectorResult {
+	scope := NewScope("", category)
+	defer scope.Close()
+	return s.SelectScope(scope, content)
+}
+
+func (s NamedNodeSelectors) SelectScope(scope *Scope, content string) []NamedSelectorResult {
 	result := []NamedSelectorResult{}
 	for i, x := range s {
-		rs, err := x.Select(category, content)
+		rs, err := x.SelectScope(scope, content)
 		if err != nil {
 			result = append(result, NamedSelectorResult{
 				Index: i,
@@ -168,6 +240,80 @@ func (s NamedNodeSelectors) Select(category, content string) []NamedSelectorResu
 	return result
 }
 
+// SelectFile selects nodes from the whole content of a file if the selector is a FileNodeSelectorIface.
+func (s NamedNodeSelector) SelectFile(scope *Scope, lines []Line) ([]FileNode, error) {
+	selector, ok := s.selector.(FileNodeSelectorIface)
+	if !ok {
+		return nil, fmt.Errorf("%w: node(%s)", ErrUnmatched, s.name)
+	}
+	r, err := AddMetric(fmt.Sprintf("named-file-node-selector-%s", s.name), func() ([]FileNode, error) {
+		return selector.SelectFile(scope, lines)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: node(%s)", err, s.name)
+	}
+	return r, nil
+}
+
+// HasFileSelector returns true if there is a FileNodeSelectorIface.
+func (s NamedNodeSelectors) HasFileSelector() bool {
+	for _, x := range s {
+		if _, ok := x.selector.(FileNodeSelectorIface); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// HasFileSelectorFor returns true if there is a FileNodeSelectorIface for the category of the scope.
+func (s NamedNodeSelectors) HasFileSelectorFor(scope *Scope) bool {
+	for _, x := range s {
+		if f, ok := x.selector.(FileNodeSelectorIface); ok && f.MatchCategory(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+type NamedFileNodeResult struct {
+	Line Line
+	Node NamedSelectorResult
+}
+
+// SelectFile selects nodes from the whole content of a file by FileNodeSelectorIface.
+func (s NamedNodeSelectors) SelectFile(scope *Scope, lines []Line) []NamedFileNodeResult {
+	result := []NamedFileNodeResult{}
+	for i, x := range s {
+		if _, ok := x.selector.(FileNodeSelectorIface); !ok {
+			continue
+		}
+		nodes, err := x.SelectFile(scope, lines)
+		if err != nil {
+			result = append(result, NamedFileNodeResult{
+				Node: NamedSelectorResult{
+					Index: i,
+					Name:  x.name,
+					Err:   err,
+				},
+			})
+			continue
+		}
+		for _, node := range nodes {
+			for _, r := range node.Result {
+				result = append(result, NamedFileNodeResult{
+					Line: node.Line,
+					Node: NamedSelectorResult{
+						Index:  i,
+						Name:   x.name,
+						Result: r,
+					},
+				})
+			}
+		}
+	}
+	return result
+}
+
 type NamedNormalizers []NamedMatcher
 
 func (n NamedNormalizers) Close() error {
@@ -10,7 +10,7 @@ import (
 
 type MockCategorySelectorFunc func() ([]string, error)
 
-func (f MockCategorySelectorFunc) Select(_ string) ([]string, error) {
+func (f MockCategorySelectorFunc) Select(_ *grdep.File) ([]string, error) {
 	return f()
 }
 
@@ -101,12 +101,53 @@ func TestNamedCategorySelectors(t *testing.T) {
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			defer tc.selectors.Close()
-			got := tc.selectors.Select("")
+			got := tc.selectors.Select(grdep.NewFile("", nil, nil))
 			assert.Equal(t, tc.want, got)
 		})
 	}
 }
 
+func TestNamedCategorySelectorsSelectMode(t *testing.T) {
+	newSelector := func(name string, priority int, result []string) *grdep.NamedCategorySelector {
+		return grdep.NewNamedCategorySelector(name, MockCategorySelectorFunc(func() ([]string, error) {
+			if result == nil {
+				return nil, grdep.ErrUnmatched
+			}
+			return result, nil
+		}), grdep.WithCategoryPriority(priority))
+	}
+	selectors := grdep.NamedCategorySelectors([]*grdep.NamedCategorySelector{
+		newSelector("a", 0, nil),
+		newSelector("b", 0, []string{"b"}),
+		newSelector("c", 1, []string{"c"}),
+		newSelector("d", 1, []string{"d"}),
+		newSelector("e", 2, nil),
+	})
+
+	results := func(rs []grdep.NamedSelectorResult) []string {
+		got := []string{}
+		for _, x := range rs {
+			if x.Err == nil {
+				got = append(got, x.Result)
+			}
+		}
+		return got
+	}
+	for _, tc := range []struct {
+		mode string
+		want []string
+	}{
+		{mode: grdep.CategoryModeAll, want: []string{"b", "c", "d"}},
+		{mode: grdep.CategoryModeFirst, want: []string{"b"}},
+		{mode: grdep.CategoryModePriority, want: []string{"c", "d"}},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			got := selectors.SelectMode(grdep.NewFile("", nil, nil), tc.mode)
+			assert.Equal(t, tc.want, results(got))
+		})
+	}
+}
+
 func TestNamedNormalizers(t *testing.T) {
 	newRegexp := func(pattern string) *grdep.Regexp {
 		v := grdep.NewRegexp(pattern)
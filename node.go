@@ -1,7 +1,14 @@
 package grdep
 
+import (
+	"slices"
+	"strings"
+)
+
 type NodeSelectorIface interface {
 	Select(category, content string) ([]string, error)
+	// SelectScope selects nodes from the content in the category of the scope.
+	SelectScope(scope *Scope, content string) ([]string, error)
 	Close() error
 }
 
@@ -9,23 +16,67 @@ var (
 	_ NodeSelectorIface = &NodeSelector{}
 )
 
-func NewNodeSelector(category Regexp, selector MatcherIface) *NodeSelector {
-	return &NodeSelector{
+type NodeSelectorOption func(*NodeSelector)
+
+// WithBlock limits lines to select to blocks between the begin line and the end line.
+// The begin line and the end line are not selected.
+func WithBlock(begin, end Regexp) NodeSelectorOption {
+	return func(n *NodeSelector) {
+		n.block = &nodeBlock{
+			begin: begin,
+			end:   end,
+		}
+	}
+}
+
+func NewNodeSelector(category Regexp, selector MatcherIface, opt ...NodeSelectorOption) *NodeSelector {
+	n := &NodeSelector{
 		category: category,
 		selector: selector,
 	}
+	for _, f := range opt {
+		f(n)
+	}
+	return n
 }
 
 type NodeSelector struct {
 	category Regexp
 	selector MatcherIface
+	block    *nodeBlock
+}
+
+type nodeBlock struct {
+	begin Regexp
+	end   Regexp
+}
+
+// inside updates the block state of the scope and returns true if the content is in a block.
+func (b *nodeBlock) inside(scope *Scope, content string) bool {
+	v, _ := scope.Value(b)
+	inBlock, _ := v.(bool)
+	if inBlock && !b.end.Unwrap().MatchString(content) {
+		return true
+	}
+	// the end line can be the begin line of the next block
+	scope.SetValue(b, b.begin.Unwrap().MatchString(content))
+	return false
 }
 
 func (n NodeSelector) Select(category, content string) ([]string, error) {
-	if !n.category.Unwrap().MatchString(category) {
+	scope := NewScope("", category)
+	defer scope.Close()
+	return n.SelectScope(scope, content)
+}
+
+func (n NodeSelector) SelectScope(scope *Scope, content string) ([]string, error) {
+	if !scope.MatchCategory(n.category) {
 		return nil, ErrUnmatched
 	}
-	r, err := n.selector.Match(content)
+	if n.block != nil && !n.block.inside(scope, content) {
+		return nil, ErrUnmatched
+	}
+	r, err := MatchScope(n.selector, scope, content)
 	if err != nil {
 		return nil, err
 	}
@@ -38,3 +89,137 @@ func (n NodeSelector) Close() error {
 	}
 	return n.selector.Close()
 }
+
+// FileNode is a node found in the whole content of a file.
+type FileNode struct {
+	// Line is the matched text and its line numbers.
+	Line   Line
+	Result []string
+}
+
+// FileNodeSelectorIface selects nodes from the whole content of a file.
+type FileNodeSelectorIface interface {
+	SelectFile(scope *Scope, lines []Line) ([]FileNode, error)
+	// MatchCategory returns true if the selector selects nodes in the category of the scope.
+	MatchCategory(scope *Scope) bool
+}
+
+var (
+	_ NodeSelectorIface     = &FileNodeSelector{}
+	_ FileNodeSelectorIface = &FileNodeSelector{}
+)
+
+// NewFileNodeSelector returns a selector that runs the regexp with multiline flag across the content of a file.
+// Each match is expanded by the template if it is not empty, and passed to the selector if it is not nil.
+func NewFileNodeSelector(category, regex Regexp, template string, selector MatcherIface) *FileNodeSelector {
+	return &FileNodeSelector{
+		category: category,
+		regex:    NewRegexp("(?m)" + regex.Unwrap().String()),
+		template: template,
+		selector: selector,
+	}
+}
+
+type FileNodeSelector struct {
+	category Regexp
+	regex    Regexp
+	template string
+	selector MatcherIface
+}
+
+// Select does not select lines.
+func (FileNodeSelector) Select(_, _ string) ([]string, error) {
+	return nil, ErrUnmatched
+}
+
+// SelectScope does not select lines.
+func (FileNodeSelector) SelectScope(_ *Scope, _ string) ([]string, error) {
+	return nil, ErrUnmatched
+}
+
+func (n FileNodeSelector) Close() error {
+	if n.selector == nil {
+		return nil
+	}
+	return n.selector.Close()
+}
+
+func (n FileNodeSelector) MatchCategory(scope *Scope) bool {
+	return scope.MatchCategory(n.category)
+}
+
+// SelectFile joins lines with newlines and finds all matches.
+func (n FileNodeSelector) SelectFile(scope *Scope, lines []Line) ([]FileNode, error) {
+	if !n.MatchCategory(scope) {
+		return nil, ErrUnmatched
+	}
+
+	var (
+		content  strings.Builder
+		offsets  = make([]int, len(lines)) // start offsets of lines
+		regex    = n.regex.Unwrap()
+		template = expandVars(n.template, regex, scope.Vars)
+		result   []FileNode
+	)
+	for i, x := range lines {
+		if i > 0 {
+			content.WriteByte('\n')
+		}
+		offsets[i] = content.Len()
+		content.WriteString(x.Content)
+	}
+	src := content.String()
+	lineAt := func(offset int) Line {
+		i, found := slices.BinarySearch(offsets, offset)
+		if !found {
+			i--
+		}
+		return lines[i]
+	}
+
+	for _, m := range regex.FindAllStringSubmatchIndex(src, -1) {
+		var (
+			text  = src[m[0]:m[1]]
+			first = lineAt(m[0])
+			last  = first
+		)
+		if m[1] > m[0] {
+			last = lineAt(m[1] - 1)
+		}
+		line := Line{
+			Linum:       first.Linum,
+			Content:     text,
+			Path:        first.Path,
+			RealPath:    first.RealPath,
+			Layer:       first.Layer,
+			Compression: first.Compression,
+		}
+		if last.Linum != first.Linum {
+			line.EndLinum = last.Linum
+		}
+
+		value := text
+		if template != "" {
+			value = string(regex.ExpandString(nil, template, src, m))
+		}
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+		r := []string{value}
+		if n.selector != nil {
+			var err error
+			if r, err = MatchScope(n.selector, scope, value); err != nil {
+				continue
+			}
+		}
+		result = append(result, FileNode{
+			Line:   line,
+			Result: r,
+		})
+	}
+
+	if len(result) == 0 {
+		return nil, ErrUnmatched
+	}
+	return result, nil
+}
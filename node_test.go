@@ -58,4 +58,177 @@ func TestNodeSelector(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Block", func(t *testing.T) {
+		selector := grdep.NewNodeSelector(
+			grdep.NewRegexp(`go`),
+			MockMatcherFunc(func() ([]string, error) {
+				return []string{"matched"}, nil
+			}),
+			grdep.WithBlock(grdep.NewRegexp(`^import \($`), grdep.NewRegexp(`^\)$`)),
+		)
+		defer selector.Close()
+
+		scope := grdep.NewScope("main.go", "go")
+		defer scope.Close()
+		got := []string{}
+		for _, line := range []string{
+			`// "x"`,
+			`import (`,
+			`	"fmt"`,
+			`	"os"`,
+			`)`,
+			`var s = "y"`,
+			`import (`,
+			`	"io"`,
+		} {
+			if _, err := selector.SelectScope(scope, line); err == nil {
+				got = append(got, line)
+			}
+		}
+		assert.Equal(t, []string{"\t\"fmt\"", "\t\"os\"", "\t\"io\""}, got)
+	})
+
+	t.Run("Ancestors", func(t *testing.T) {
+		selector := grdep.NewNodeSelector(
+			grdep.NewRegexp(`^container$`),
+			MockMatcherFunc(func() ([]string, error) {
+				return []string{"matched"}, nil
+			}),
+		)
+		defer selector.Close()
+
+		scope := grdep.NewScope("Dockerfile", "dockerfile")
+		defer scope.Close()
+		_, err := selector.SelectScope(scope, "FROM debian")
+		assert.ErrorIs(t, err, grdep.ErrUnmatched)
+
+		scope.Ancestors = []string{"container"}
+		got, err := selector.SelectScope(scope, "FROM debian")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"matched"}, got)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		lines := []grdep.Line{
+			{Linum: 1, Content: "FROM debian", Path: "Dockerfile"},
+			{Linum: 2, Content: "RUN apt-get install \\", Path: "Dockerfile"},
+			{Linum: 3, Content: "    curl", Path: "Dockerfile"},
+			{Linum: 4, Content: "RUN apt-get install git", Path: "Dockerfile"},
+		}
+		for _, tc := range []struct {
+			name     string
+			selector *grdep.FileNodeSelector
+			category string
+			want     []grdep.FileNode
+			err      error
+		}{
+			{
+				name: "category unmatched",
+				selector: grdep.NewFileNodeSelector(
+					grdep.NewRegexp(`docker`), grdep.NewRegexp(`.+`), "", nil,
+				),
+				category: "go",
+				err:      grdep.ErrUnmatched,
+			},
+			{
+				name: "unmatched",
+				selector: grdep.NewFileNodeSelector(
+					grdep.NewRegexp(`docker`), grdep.NewRegexp(`^ENTRYPOINT`), "", nil,
+				),
+				category: "docker",
+				err:      grdep.ErrUnmatched,
+			},
+			{
+				name: "matched",
+				selector: grdep.NewFileNodeSelector(
+					grdep.NewRegexp(`docker`),
+					grdep.NewRegexp(`install(?:\s|\\)+(?P<v>\w+)$`),
+					"$v",
+					nil,
+				),
+				category: "docker",
+				want: []grdep.FileNode{
+					{
+						Line: grdep.Line{
+							Linum:    2,
+							EndLinum: 3,
+							Content:  "install \\\n    curl",
+							Path:     "Dockerfile",
+						},
+						Result: []string{"curl"},
+					},
+					{
+						Line: grdep.Line{
+							Linum:   4,
+							Content: "install git",
+							Path:    "Dockerfile",
+						},
+						Result: []string{"git"},
+					},
+				},
+			},
+			{
+				name: "selector",
+				selector: grdep.NewFileNodeSelector(
+					grdep.NewRegexp(`docker`),
+					grdep.NewRegexp(`^FROM .+$`),
+					"",
+					MockMatcherFunc(func() ([]string, error) {
+						return []string{"image"}, nil
+					}),
+				),
+				category: "docker",
+				want: []grdep.FileNode{
+					{
+						Line: grdep.Line{
+							Linum:   1,
+							Content: "FROM debian",
+							Path:    "Dockerfile",
+						},
+						Result: []string{"image"},
+					},
+				},
+			},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				defer tc.selector.Close()
+				scope := grdep.NewScope("Dockerfile", tc.category)
+				defer scope.Close()
+				got, err := tc.selector.SelectFile(scope, lines)
+				if tc.err != nil {
+					assert.ErrorIs(t, err, tc.err)
+					return
+				}
+				assert.Nil(t, err)
+				assert.Equal(t, tc.want, got)
+			})
+		}
+	})
+
+	t.Run("HasFileSelectorFor", func(t *testing.T) {
+		selectors := grdep.NamedNodeSelectors([]*grdep.NamedNodeSelector{
+			grdep.NewNamedNodeSelector("line", grdep.NewNodeSelector(grdep.NewRegexp(`go`), grdep.MatcherSet(nil))),
+			grdep.NewNamedNodeSelector("file", grdep.NewFileNodeSelector(
+				grdep.NewRegexp(`^docker$`), grdep.NewRegexp(`.+`), "", nil,
+			)),
+		})
+		defer selectors.Close()
+		for _, tc := range []struct {
+			category  string
+			ancestors []string
+			want      bool
+		}{
+			{category: "docker", want: true},
+			{category: "go", want: false},
+			{category: "compose", ancestors: []string{"docker"}, want: true},
+		} {
+			t.Run(tc.category, func(t *testing.T) {
+				scope := grdep.NewScope("", tc.category)
+				defer scope.Close()
+				scope.Ancestors = tc.ancestors
+				assert.Equal(t, tc.want, selectors.HasFileSelectorFor(scope))
+			})
+		}
+	})
 }
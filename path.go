@@ -0,0 +1,109 @@
+package grdep
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+type ancestorDir struct {
+	// dir is relative if the path is relative, even above the working directory.
+	dir string
+	abs string
+}
+
+// ancestorDirs returns the directory of the path and its ancestors up to the root, nearest first.
+func ancestorDirs(path string) ([]ancestorDir, error) {
+	dir := filepath.Dir(path)
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []ancestorDir{}
+	for {
+		result = append(result, ancestorDir{
+			dir: dir,
+			abs: abs,
+		})
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return result, nil
+		}
+		abs = parent
+		dir = filepath.Join(dir, "..")
+	}
+}
+
+// fileExists returns true if the file exists.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+const gitDir = ".git"
+
+// gitRepoDirs returns the ancestor directories of the path up to the root of the git repository
+// and the absolute path of the root of the repository.
+// If the path is not in a git repository, returns the ancestors up to the root of the walk and empty repository root,
+// so files in unrelated parents like $HOME/.gitignore are not read.
+// exists reports whether .git exists, callers cache it because files in the same directory share ancestors.
+func gitRepoDirs(walkRoot, path string, exists func(string) bool) ([]ancestorDir, string, error) {
+	dirs, err := ancestorDirs(path)
+	if err != nil {
+		return nil, "", err
+	}
+	for i, x := range dirs {
+		if exists(filepath.Join(x.abs, gitDir)) {
+			return dirs[:i+1], x.abs, nil
+		}
+	}
+	return walkRootDirs(dirs, walkRoot), "", nil
+}
+
+// walkRootDirs returns the dirs up to the root of the walk, a directory or a file,
+// or only the directory of the path if the path is not under the root.
+func walkRootDirs(dirs []ancestorDir, walkRoot string) []ancestorDir {
+	if walkRoot == "" {
+		return dirs[:1]
+	}
+	abs, err := filepath.Abs(walkRoot)
+	if err != nil {
+		return dirs[:1]
+	}
+	parent := filepath.Dir(abs)
+	for i, x := range dirs {
+		// nearest first, so the root itself precedes its parent if it is a directory
+		if x.abs == abs || x.abs == parent {
+			return dirs[:i+1]
+		}
+	}
+	return dirs[:1]
+}
+
+// readGitLines returns the lines of the file like .gitignore, nil if the file cannot be opened.
+// Lines longer than DefaultMaxLineLength are skipped, and the lines before a read error are returned.
+func readGitLines(path string) []string {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer fp.Close()
+
+	var (
+		lines []string
+		r     = NewLineReader(fp, WithLongLine(LongLineSkip))
+	)
+	for {
+		x, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return lines
+		}
+		if err != nil {
+			L().Warn("read git file", "path", path, "err", err)
+			return lines
+		}
+		lines = append(lines, x.Text)
+	}
+}
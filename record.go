@@ -0,0 +1,128 @@
+package grdep
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+var ErrRecord = errors.New("Record")
+
+var (
+	_ WalkerIface = &RecordWalker{}
+)
+
+// Record is a file given by the content instead of the filesystem.
+// Either Content or Lines is required.
+type Record struct {
+	Path    string   `json:"path"`
+	Content *string  `json:"content,omitempty"`
+	Lines   []string `json:"lines,omitempty"`
+}
+
+// Validate returns an error if the record has no path, or not exactly one of the content and the lines.
+func (r Record) Validate() error {
+	if r.Path == "" {
+		return fmt.Errorf("%w: path is required", ErrRecord)
+	}
+	if (r.Content == nil) == (r.Lines == nil) {
+		return fmt.Errorf("%w: either content or lines is required: %s", ErrRecord, r.Path)
+	}
+	return nil
+}
+
+func (r Record) content() []byte {
+	if r.Content != nil {
+		return []byte(*r.Content)
+	}
+	return []byte(strings.Join(r.Lines, "\n"))
+}
+
+// NewRecordWalker returns a walker of the records read from r as JSON lines like
+//
+//	{"path":"ci/build.log","content":"..."}
+//	{"path":"db/scripts/1.sh","lines":["#!/bin/bash","..."]}
+//
+// The files have the content of the records and the index of the records as Record.
+// Marker and gitattributes category selectors do not match them because they are not on the filesystem,
+// and the skips of opt should not look up the filesystem either.
+// An invalid record is yielded as a file that fails to read, and the rest are not read.
+func NewRecordWalker(r io.Reader, ignores MatcherIface, opt ...WalkerOption) *RecordWalker {
+	return &RecordWalker{
+		r:      r,
+		walker: NewWalker("", ignores, opt...),
+	}
+}
+
+type RecordWalker struct {
+	r      io.Reader
+	walker *Walker
+}
+
+func (w RecordWalker) Walk(ctx context.Context) <-chan *File {
+	resultC := make(chan *File, 100)
+
+	go func() {
+		defer close(resultC)
+
+		dec := json.NewDecoder(w.r)
+		for i := 1; ; i++ {
+			if IsDone(ctx) {
+				return
+			}
+
+			var record Record
+			if err := dec.Decode(&record); err != nil {
+				if !errors.Is(err, io.EOF) {
+					resultC <- newErrFile("", fmt.Errorf("%w: %w: record %d", ErrRecord, err, i))
+				}
+				return
+			}
+			if err := record.Validate(); err != nil {
+				resultC <- newErrFile(record.Path, fmt.Errorf("%w: record %d", err, i))
+				return
+			}
+
+			var (
+				content = record.content()
+				info    = recordInfo{
+					name: path.Base(record.Path),
+					size: int64(len(content)),
+				}
+			)
+			if w.walker.isSkip(record.Path, info) {
+				continue
+			}
+			AddMetricCount("walk-record", 1)
+			f := NewFile(record.Path, info, func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(content)), nil
+			})
+			f.Record = i
+			w.walker.send(ctx, resultC, f, w.walker.archiveDepth)
+		}
+	}()
+
+	return resultC
+}
+
+// recordInfo is the fs.FileInfo of a record, a regular file readable by everyone.
+type recordInfo struct {
+	name string
+	size int64
+}
+
+var _ fs.FileInfo = recordInfo{}
+
+func (i recordInfo) Name() string     { return i.name }
+func (i recordInfo) Size() int64      { return i.size }
+func (recordInfo) Mode() fs.FileMode  { return 0o444 }
+func (recordInfo) ModTime() time.Time { return time.Time{} }
+func (recordInfo) IsDir() bool        { return false }
+func (recordInfo) Sys() any           { return nil }
@@ -0,0 +1,154 @@
+package grdep_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/berquerant/grdep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordWalker(t *testing.T) {
+	newRegexp := func(pattern string) *grdep.Regexp {
+		v := grdep.NewRegexp(pattern)
+		return &v
+	}
+	type file struct {
+		Path   string
+		Record int
+		Lines  []string
+		Err    bool
+	}
+
+	for _, tc := range []struct {
+		title   string
+		input   string
+		ignores grdep.MatcherIface
+		want    []file
+	}{
+		{
+			title: "empty",
+			input: "",
+			want:  []file{},
+		},
+		{
+			title: "content and lines",
+			input: `{"path":"ci/build.log","content":"a\nb\n"}
+{"path":"db/1.sh","lines":["#!/bin/bash",". lib.sh"]}
+{"path":"empty","lines":[]}`,
+			want: []file{
+				{
+					Path:   "ci/build.log",
+					Record: 1,
+					Lines:  []string{"a", "b"},
+				},
+				{
+					Path:   "db/1.sh",
+					Record: 2,
+					Lines:  []string{"#!/bin/bash", ". lib.sh"},
+				},
+				{
+					Path:   "empty",
+					Record: 3,
+					Lines:  []string{},
+				},
+			},
+		},
+		{
+			title: "same path",
+			input: `{"path":"x.sh","content":"a"}
+{"path":"x.sh","content":"b"}`,
+			want: []file{
+				{
+					Path:   "x.sh",
+					Record: 1,
+					Lines:  []string{"a"},
+				},
+				{
+					Path:   "x.sh",
+					Record: 2,
+					Lines:  []string{"b"},
+				},
+			},
+		},
+		{
+			title: "ignore",
+			input: `{"path":"vendor/x.sh","content":"x"}
+{"path":"y.sh","content":"y"}`,
+			ignores: grdep.MatcherSet([]*grdep.Matcher{
+				{
+					Regex: newRegexp(`^vendor/`),
+				},
+			}),
+			want: []file{
+				{
+					Path:   "y.sh",
+					Record: 2,
+					Lines:  []string{"y"},
+				},
+			},
+		},
+		{
+			title: "no content",
+			input: `{"path":"x.sh","content":"x"}
+{"path":"y.sh"}
+{"path":"z.sh","content":"z"}`,
+			want: []file{
+				{
+					Path:   "x.sh",
+					Record: 1,
+					Lines:  []string{"x"},
+				},
+				{
+					Path: "y.sh",
+					Err:  true,
+				},
+			},
+		},
+		{
+			title: "both content and lines",
+			input: `{"path":"x.sh","content":"x","lines":["x"]}`,
+			want: []file{
+				{
+					Path: "x.sh",
+					Err:  true,
+				},
+			},
+		},
+		{
+			title: "invalid json",
+			input: `{"path":`,
+			want: []file{
+				{
+					Err: true,
+				},
+			},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			ignores := tc.ignores
+			if ignores == nil {
+				ignores = grdep.MatcherSet(nil)
+			}
+			got := []file{}
+			for f := range grdep.NewRecordWalker(strings.NewReader(tc.input), ignores).Walk(context.TODO()) {
+				x := file{
+					Path:   f.Path,
+					Record: f.Record,
+					Lines:  []string{},
+				}
+				for line := range f.Lines(context.TODO()) {
+					if line.Err != nil {
+						x.Err = true
+						x.Lines = nil
+						break
+					}
+					x.Lines = append(x.Lines, line.Content)
+				}
+				got = append(got, x)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package grdep
+
+import "errors"
+
+// Scope is the state of scanning a file in a category.
+// Stateful matchers and selectors keep their state per file in it.
+type Scope struct {
+	Path     string
+	Category string
+	// Ancestors are the ancestors of the category, see CategoryHierarchy.
+	Ancestors []string
+	// Vars are the variables of the file, available in templates.
+	Vars map[string]string
+	// Line is the line being scanned.
+	Line Line
+
+	values  map[any]any
+	closers []func() error
+}
+
+func NewScope(path, category string) *Scope {
+	return &Scope{
+		Path:     path,
+		Category: category,
+		values:   map[any]any{},
+	}
+}
+
+// MatchCategory returns true if the category or any of the ancestors matches.
+func (s *Scope) MatchCategory(r Regexp) bool {
+	re := r.Unwrap()
+	if re.MatchString(s.Category) {
+		return true
+	}
+	for _, x := range s.Ancestors {
+		if re.MatchString(x) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scope) Value(key any) (any, bool) {
+	v, ok := s.values[key]
+	return v, ok
+}
+
+func (s *Scope) SetValue(key, value any) {
+	s.values[key] = value
+}
+
+// OnClose registers f to be called at the end of the file.
+func (s *Scope) OnClose(f func() error) {
+	s.closers = append(s.closers, f)
+}
+
+// Close ends the file, calls registered functions in reverse order.
+func (s *Scope) Close() error {
+	var errs []error
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		errs = append(errs, s.closers[i]())
+	}
+	s.closers = nil
+	s.values = map[any]any{}
+	return errors.Join(errs...)
+}
+
+// ScopedMatcherIface is a matcher that can depend on the file being scanned.
+type ScopedMatcherIface interface {
+	MatchScope(scope *Scope, src string) ([]string, error)
+}
+
+var (
+	_ ScopedMatcherIface = &Matcher{}
+	_ ScopedMatcherIface = &__ms
+	_ ScopedMatcherIface = &NamedMatcher{}
+)
+
+// MatchScope calls MatchScope if m is a ScopedMatcherIface and scope is not nil, otherwise Match.
+func MatchScope(m MatcherIface, scope *Scope, src string) ([]string, error) {
+	if x, ok := m.(ScopedMatcherIface); ok && scope != nil {
+		return x.MatchScope(scope, src)
+	}
+	return m.Match(src)
+}
@@ -0,0 +1,108 @@
+package grdep
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidSemver = errors.New("InvalidSemver")
+)
+
+type semver struct {
+	core       [3]int
+	prerelease []string
+}
+
+// parseSemver parses a semantic version like v1.2.3-rc.1+build.
+// The prefix v, minor and patch are optional.
+func parseSemver(s string) (*semver, error) {
+	v := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i]
+	}
+	var r semver
+	if i := strings.Index(v, "-"); i >= 0 {
+		if v[i+1:] == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSemver, s)
+		}
+		r.prerelease = strings.Split(v[i+1:], ".")
+		v = v[:i]
+	}
+	xs := strings.Split(v, ".")
+	if len(xs) > 3 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSemver, s)
+	}
+	for i, x := range xs {
+		n, err := strconv.Atoi(x)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidSemver, s)
+		}
+		r.core[i] = n
+	}
+	return &r, nil
+}
+
+func (v semver) compare(other semver) int {
+	for i := range v.core {
+		if c := compareInt(v.core[i], other.core[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(v.prerelease) == 0 && len(other.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(other.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(other.prerelease); i++ {
+		if c := comparePrerelease(v.prerelease[i], other.prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(v.prerelease), len(other.prerelease))
+}
+
+func comparePrerelease(a, b string) int {
+	x, xErr := strconv.Atoi(a)
+	y, yErr := strconv.Atoi(b)
+	switch {
+	case xErr == nil && yErr == nil:
+		return compareInt(x, y)
+	case xErr == nil:
+		// numeric identifiers have lower precedence
+		return -1
+	case yErr == nil:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// CompareSemver returns -1, 0 or 1 when a is less than, equal to or greater than b.
+func CompareSemver(a, b string) (int, error) {
+	x, err := parseSemver(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := parseSemver(b)
+	if err != nil {
+		return 0, err
+	}
+	return x.compare(*y), nil
+}
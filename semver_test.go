@@ -0,0 +1,38 @@
+package grdep_test
+
+import (
+	"testing"
+
+	"github.com/berquerant/grdep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareSemver(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+		err  error
+	}{
+		{a: "1.2.3", b: "1.2.3", want: 0},
+		{a: "v1.2.3", b: "1.2.3", want: 0},
+		{a: "1.2.3", b: "1.10.0", want: -1},
+		{a: "2", b: "1.9.9", want: 1},
+		{a: "1.0.0-alpha", b: "1.0.0", want: -1},
+		{a: "1.0.0-alpha.1", b: "1.0.0-alpha", want: 1},
+		{a: "1.0.0-alpha.2", b: "1.0.0-alpha.10", want: -1},
+		{a: "1.0.0-1", b: "1.0.0-alpha", want: -1},
+		{a: "1.0.0+build", b: "1.0.0", want: 0},
+		{a: "x", b: "1.0.0", err: grdep.ErrInvalidSemver},
+		{a: "1.0.0.0", b: "1.0.0", err: grdep.ErrInvalidSemver},
+	} {
+		t.Run(tc.a+"_"+tc.b, func(t *testing.T) {
+			got, err := grdep.CompareSemver(tc.a, tc.b)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package grdep
+
+import (
+	"io/fs"
+	"time"
+)
+
+// FileStat is a condition on the metadata of a file.
+// Nil and zero values mean no condition.
+type FileStat struct {
+	// Executable requires any of the executable bits to be set or not.
+	Executable *bool
+	// Symlink requires the file to be a symlink or not.
+	Symlink *bool
+	// MinSize and MaxSize limit the size in bytes, inclusive.
+	MinSize *int64
+	MaxSize *int64
+	// ModifiedAfter and ModifiedBefore limit the modification time, exclusive.
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+}
+
+// Match returns true if the metadata matches.
+// info is the metadata of the file itself like lstat, used for Symlink.
+// target is the metadata of the file that the symlink points to like stat, used for the other conditions,
+// it is the same as info if the file is not a symlink, and nil if the target does not exist.
+func (s FileStat) Match(info, target fs.FileInfo) bool {
+	if s.Symlink != nil && *s.Symlink != (info.Mode()&fs.ModeSymlink != 0) {
+		return false
+	}
+	if !s.hasTargetCondition() {
+		return true
+	}
+	if target == nil {
+		return false
+	}
+	if s.Executable != nil && *s.Executable != (target.Mode().Perm()&0o111 != 0) {
+		return false
+	}
+	if s.MinSize != nil && target.Size() < *s.MinSize {
+		return false
+	}
+	if s.MaxSize != nil && target.Size() > *s.MaxSize {
+		return false
+	}
+	if !s.ModifiedAfter.IsZero() && !target.ModTime().After(s.ModifiedAfter) {
+		return false
+	}
+	if !s.ModifiedBefore.IsZero() && !target.ModTime().Before(s.ModifiedBefore) {
+		return false
+	}
+	return true
+}
+
+func (s FileStat) hasTargetCondition() bool {
+	return s.Executable != nil || s.MinSize != nil || s.MaxSize != nil ||
+		!s.ModifiedAfter.IsZero() || !s.ModifiedBefore.IsZero()
+}
@@ -0,0 +1,88 @@
+package grdep
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// VarProjectRoot is the default variable of the directory found by the marker category selector.
+	VarProjectRoot = "project_root"
+)
+
+// expandVars replaces $name and ${name} of the variables in the template.
+// Submatches of the regexp take precedence over the variables, they remain for regexp.Expand,
+// as well as the other $ sequences like $1 and $$.
+func expandVars(template string, re *regexp.Regexp, vars map[string]string) string {
+	if len(vars) == 0 {
+		return template
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(template, '$')
+		if i < 0 {
+			break
+		}
+		b.WriteString(template[:i])
+		template = template[i:]
+		if strings.HasPrefix(template, "$$") {
+			b.WriteString("$$")
+			template = template[2:]
+			continue
+		}
+		if name, rest, ok := extractVarName(template[1:]); ok {
+			if v, found := vars[name]; found && re.SubexpIndex(name) < 0 {
+				b.WriteString(strings.ReplaceAll(v, "$", "$$"))
+				template = rest
+				continue
+			}
+		}
+		b.WriteByte('$')
+		template = template[1:]
+	}
+	b.WriteString(template)
+	return b.String()
+}
+
+// extractVarName returns the name of name or {name} at the beginning of s and the rest of s,
+// a name consists of letters, digits and underscores like regexp.Expand.
+func extractVarName(s string) (name, rest string, ok bool) {
+	if strings.HasPrefix(s, "{") {
+		j := strings.IndexByte(s, '}')
+		if j < 0 || !isVarName(s[1:j]) {
+			return "", s, false
+		}
+		return s[1:j], s[j+1:], true
+	}
+	j := 0
+	for j < len(s) {
+		r, size := utf8.DecodeRuneInString(s[j:])
+		if !isVarNameRune(r) {
+			break
+		}
+		j += size
+	}
+	if j == 0 {
+		return "", s, false
+	}
+	return s[:j], s[j:], true
+}
+
+func isVarName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !isVarNameRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isVarNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
@@ -1,16 +1,20 @@
 package grdep
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type WalkerIface interface {
-	// Walk walks the file tree and read each lines of files.
-	Walk(ctx context.Context) <-chan Line
+	// Walk walks the file tree and yields files.
+	// The content of a file is read lazily.
+	Walk(ctx context.Context) <-chan *File
 }
 
 var (
@@ -18,39 +22,137 @@ var (
 )
 
 type Line struct {
-	Linum   int    `json:"linum"`
-	Content string `json:"content"`
-	Path    string `json:"path"`
-	Err     error  `json:"err,omitempty"`
+	Linum int `json:"linum"`
+	// EndLinum is the last line number of a logical line that consists of multiple lines.
+	EndLinum int    `json:"end_linum,omitempty"`
+	Content  string `json:"content"`
+	Path     string `json:"path"`
+	// RealPath is the path with symlinks resolved, empty unless symlinks are followed.
+	RealPath string `json:"real_path,omitempty"`
+	// Layer is the digest of the layer of the container image that provides the file.
+	Layer string `json:"layer,omitempty"`
+	// Compression is the compression of the file like gzip, the content is decompressed.
+	Compression string `json:"compression,omitempty"`
+	Err         error  `json:"err,omitempty"`
+	// Truncated is true if the line is longer than the max line length.
+	Truncated bool `json:"truncated,omitempty"`
+	// InComment is true if the line consists of comments only, see CommentFilter.
+	InComment bool `json:"in_comment,omitempty"`
 }
 
 func (r Line) String() string {
 	return fmt.Sprintf("at %s:%d:%s", r.Path, r.Linum, r.Content)
 }
 
-func NewWalker(root string, ignores MatcherIface) *Walker {
-	return &Walker{
-		root:    root,
-		ignores: ignores,
+type WalkerOption func(*Walker)
+
+// WithSkip skips files and directories that f returns true.
+func WithSkip(f func(path string, info fs.FileInfo) bool) WalkerOption {
+	return func(w *Walker) {
+		w.skips = append(w.skips, f)
+	}
+}
+
+// WithFollowSymlinks descends into symlinked directories.
+// Directories and files reached through several paths are yielded once, including cycles.
+func WithFollowSymlinks() WalkerOption {
+	return func(w *Walker) {
+		w.followSymlinks = true
+	}
+}
+
+// WithArchiveDepth descends into archives like bundle.tar.gz up to depth levels of nesting.
+// Files in archives are yielded as paths like bundle.tar.gz!/etc/app/start.sh.
+// 0 means archives are yielded as files.
+func WithArchiveDepth(depth int) WalkerOption {
+	return func(w *Walker) {
+		w.archiveDepth = depth
+	}
+}
+
+// WithArchiveMaxEntrySize limits the size of a file in archives and container images to read into memory,
+// larger files are yielded as files that fail to read by ErrArchiveEntryTooLarge.
+// The default is DefaultArchiveMaxEntrySize, non-positive means no limit.
+func WithArchiveMaxEntrySize(size int64) WalkerOption {
+	return func(w *Walker) {
+		w.archiveMaxEntrySize = size
 	}
 }
 
+// WithMaxDepth limits the depth of directories to descend like find -maxdepth,
+// 0 means only the root, 1 means the files in the root. Negative means no limit.
+func WithMaxDepth(depth int) WalkerOption {
+	return func(w *Walker) {
+		w.maxDepth = depth
+	}
+}
+
+func NewWalker(root string, ignores MatcherIface, opt ...WalkerOption) *Walker {
+	w := &Walker{
+		root:                root,
+		ignores:             ignores,
+		maxDepth:            -1,
+		archiveMaxEntrySize: DefaultArchiveMaxEntrySize,
+	}
+	for _, f := range opt {
+		f(w)
+	}
+	return w
+}
+
 type Walker struct {
-	root    string
-	ignores MatcherIface
+	root           string
+	ignores        MatcherIface
+	skips          []func(path string, info fs.FileInfo) bool
+	followSymlinks bool
+	archiveDepth   int
+	maxDepth       int
+	// archiveMaxEntrySize is the max size of a file in archives to read.
+	archiveMaxEntrySize int64
 }
 
-func (w Walker) isSkip(path string) bool {
-	_, err := w.ignores.Match(path)
-	return err == nil
+// tooDeep returns true if the path should not be yielded or descended into.
+func (w Walker) tooDeep(depth int, isDir bool) bool {
+	if w.maxDepth < 0 {
+		return false
+	}
+	if isDir {
+		return depth >= w.maxDepth
+	}
+	return depth > w.maxDepth
+}
+
+// depth returns the depth of the path from the root.
+func (w Walker) depth(path string) int {
+	rel, err := filepath.Rel(w.root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
+func (w Walker) isSkip(path string, info fs.FileInfo) bool {
+	if _, err := w.ignores.Match(path); err == nil {
+		return true
+	}
+	for _, f := range w.skips {
+		if f(path, info) {
+			return true
+		}
+	}
+	return false
 }
 
-func (w Walker) Walk(ctx context.Context) <-chan Line {
-	resultC := make(chan Line, 1000)
+func (w Walker) Walk(ctx context.Context) <-chan *File {
+	resultC := make(chan *File, 100)
 
 	go func() {
 		defer close(resultC)
 
+		if w.followSymlinks {
+			_ = w.walkFollow(ctx, resultC)
+			return
+		}
 		_ = filepath.Walk(w.root, func(path string, info fs.FileInfo, walkErr error) error {
 			if IsDone(ctx) {
 				return ctx.Err()
@@ -58,7 +160,13 @@ func (w Walker) Walk(ctx context.Context) <-chan Line {
 			if walkErr != nil {
 				return nil
 			}
-			if w.isSkip(path) {
+			if w.isSkip(path, info) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if w.tooDeep(w.depth(path), info.IsDir()) {
 				if info.IsDir() {
 					return filepath.SkipDir
 				}
@@ -67,28 +175,134 @@ func (w Walker) Walk(ctx context.Context) <-chan Line {
 			if info.IsDir() {
 				return nil
 			}
+			if info.Mode()&fs.ModeSymlink != 0 {
+				// symlinked directories are not files
+				if x, err := os.Stat(path); err == nil && x.IsDir() {
+					return nil
+				}
+			}
 
-			return w.scan(ctx, path, resultC)
+			w.send(ctx, resultC, OpenFile(path, info), w.archiveDepth)
+			return nil
 		})
 	}()
 
 	return resultC
 }
 
-func (Walker) scan(ctx context.Context, path string, resultC chan<- Line) error {
-	fp, err := os.Open(path)
+// walkFollow walks the file tree like filepath.Walk but follows symlinks.
+func (w Walker) walkFollow(ctx context.Context, resultC chan<- *File) error {
+	visited := map[fileID]bool{}
+
+	var walk func(path string, info fs.FileInfo, depth int) error
+	walk = func(path string, info fs.FileInfo, depth int) error {
+		if IsDone(ctx) {
+			return ctx.Err()
+		}
+
+		target := info
+		if info.Mode()&fs.ModeSymlink != 0 {
+			x, err := os.Stat(path)
+			if err != nil {
+				// broken link, yielded like the walk without following to report the error
+				if !w.isSkip(path, info) && !w.tooDeep(depth, false) {
+					w.send(ctx, resultC, OpenFile(path, info), w.archiveDepth)
+				}
+				return nil
+			}
+			target = x
+		}
+		if w.isSkip(path, target) || w.tooDeep(depth, target.IsDir()) {
+			return nil
+		}
+		if id, ok := getFileID(path, target); ok {
+			if visited[id] {
+				AddMetricCount("walk-visited", 1)
+				return nil
+			}
+			visited[id] = true
+		}
+
+		if !target.IsDir() {
+			file := OpenFile(path, info)
+			if realPath, err := filepath.EvalSymlinks(path); err == nil {
+				file.RealPath = realPath
+			}
+			w.send(ctx, resultC, file, w.archiveDepth)
+			return nil
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil
+		}
+		for _, x := range entries {
+			info, err := x.Info()
+			if err != nil {
+				continue
+			}
+			if err := walk(filepath.Join(path, x.Name()), info, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	info, err := os.Lstat(w.root)
 	if err != nil {
 		return err
 	}
-	defer fp.Close()
+	return walk(w.root, info, 0)
+}
 
-	for x := range ReadLines(ctx, fp) {
-		resultC <- Line{
-			Linum:   x.Linum,
-			Content: x.Text,
-			Path:    path,
-			Err:     x.Err,
+// send yields the file, or the files in it if the file is an archive and depth is positive.
+func (w Walker) send(ctx context.Context, resultC chan<- *File, file *File, depth int) {
+	if file.Root == "" {
+		file.Root = w.root
+	}
+	kind := archiveKindOf(file.Path)
+	if depth <= 0 || kind == archiveNone {
+		resultC <- file
+		return
+	}
+
+	AddMetricCount("walk-archive", 1)
+	sendErr := func(err error) {
+		resultC <- newErrFile(file.Path, fmt.Errorf("%w: %w: %s", ErrArchive, err, file.Path))
+	}
+	rc, err := file.open()
+	if err != nil {
+		sendErr(err)
+		return
+	}
+	defer rc.Close()
+
+	for x, err := range readArchive(kind, rc, w.archiveMaxEntrySize) {
+		if IsDone(ctx) {
+			return
+		}
+		if err != nil {
+			sendErr(err)
+			return
+		}
+		path := file.Path + ArchiveSeparator + x.name
+		if w.isSkip(path, x.info) {
+			continue
+		}
+		if x.err != nil {
+			resultC <- newErrFile(path, fmt.Errorf("%w: %w: %s", ErrArchive, x.err, path))
+			continue
+		}
+		content := x.content
+		f := NewFile(path, x.info, func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(content)), nil
+		})
+		if file.RealPath != "" {
+			f.RealPath = file.RealPath + ArchiveSeparator + x.name
 		}
+		f.Layer = file.Layer
+		f.Record = file.Record
+		f.Root = file.Root
+		w.send(ctx, resultC, f, depth-1)
 	}
-	return nil
 }
@@ -0,0 +1,144 @@
+package grdep_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/berquerant/grdep"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalkerFollowSymlinks(t *testing.T) {
+	root := t.TempDir()
+	for _, x := range []string{
+		"shared/lib.sh",
+		"shared/nested/x.sh",
+		"app/main.sh",
+	} {
+		p := filepath.Join(root, x)
+		if !assert.Nil(t, os.MkdirAll(filepath.Dir(p), 0755)) {
+			return
+		}
+		if !assert.Nil(t, os.WriteFile(p, nil, 0644)) {
+			return
+		}
+	}
+	for link, target := range map[string]string{
+		"app/scripts":        "../shared",        // symlinked directory
+		"app/lib.sh":         "../shared/lib.sh", // the same file
+		"shared/nested/loop": "..",               // cycle
+		"app/broken":         "missing",
+	} {
+		if !assert.Nil(t, os.Symlink(target, filepath.Join(root, link))) {
+			return
+		}
+	}
+
+	walk := func(opt ...grdep.WalkerOption) [][2]string {
+		got := [][2]string{}
+		for f := range grdep.NewWalker(filepath.Join(root, "app"), grdep.MatcherSet(nil), opt...).Walk(context.TODO()) {
+			rel, err := filepath.Rel(root, f.Path)
+			assert.Nil(t, err)
+			var realRel string
+			if f.RealPath != "" {
+				realPath, err := filepath.EvalSymlinks(root)
+				assert.Nil(t, err)
+				realRel, err = filepath.Rel(realPath, f.RealPath)
+				assert.Nil(t, err)
+			}
+			got = append(got, [2]string{filepath.ToSlash(rel), filepath.ToSlash(realRel)})
+		}
+		sort.Slice(got, func(i, j int) bool { return got[i][0] < got[j][0] })
+		return got
+	}
+
+	t.Run("follow", func(t *testing.T) {
+		assert.Equal(t, [][2]string{
+			{"app/broken", ""},
+			{"app/lib.sh", "shared/lib.sh"},
+			{"app/main.sh", "app/main.sh"},
+			{"app/scripts/nested/x.sh", "shared/nested/x.sh"},
+		}, walk(grdep.WithFollowSymlinks()))
+	})
+
+	t.Run("no follow", func(t *testing.T) {
+		assert.Equal(t, [][2]string{
+			{"app/broken", ""},
+			{"app/lib.sh", ""},
+			{"app/main.sh", ""},
+		}, walk())
+	})
+}
+
+func TestWalkerMaxDepth(t *testing.T) {
+	root := t.TempDir()
+	for _, x := range []string{
+		"a.sh",
+		"d/b.sh",
+		"d/e/c.sh",
+	} {
+		p := filepath.Join(root, x)
+		if !assert.Nil(t, os.MkdirAll(filepath.Dir(p), 0755)) {
+			return
+		}
+		if !assert.Nil(t, os.WriteFile(p, nil, 0644)) {
+			return
+		}
+	}
+
+	for _, tc := range []struct {
+		title string
+		root  string
+		depth int
+		opt   []grdep.WalkerOption
+		want  []string
+	}{
+		{
+			title: "no limit",
+			depth: -1,
+			want:  []string{"a.sh", "d/b.sh", "d/e/c.sh"},
+		},
+		{
+			title: "root only",
+			depth: 0,
+			want:  []string{},
+		},
+		{
+			title: "root file",
+			root:  "a.sh",
+			depth: 0,
+			want:  []string{"a.sh"},
+		},
+		{
+			title: "files in root",
+			depth: 1,
+			want:  []string{"a.sh"},
+		},
+		{
+			title: "2 levels",
+			depth: 2,
+			want:  []string{"a.sh", "d/b.sh"},
+		},
+		{
+			title: "2 levels follow symlinks",
+			depth: 2,
+			opt:   []grdep.WalkerOption{grdep.WithFollowSymlinks()},
+			want:  []string{"a.sh", "d/b.sh"},
+		},
+	} {
+		t.Run(tc.title, func(t *testing.T) {
+			opt := append([]grdep.WalkerOption{grdep.WithMaxDepth(tc.depth)}, tc.opt...)
+			got := []string{}
+			for f := range grdep.NewWalker(filepath.Join(root, tc.root), grdep.MatcherSet(nil), opt...).Walk(context.TODO()) {
+				rel, err := filepath.Rel(root, f.Path)
+				assert.Nil(t, err)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			sort.Strings(got)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}